@@ -4,6 +4,7 @@ import (
 	"gochop/backend/internal/db"
 	"gochop/backend/internal/handlers"
 	"gochop/backend/internal/middleware"
+	"gochop/backend/internal/services"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
@@ -28,6 +29,12 @@ func main() {
 		log.Fatalf("Database migration failed: %v", err)
 	}
 
+	// Periodically check link destinations in the background
+	go services.NewHealthMonitor().Run(db.Ctx)
+
+	// Keep a pool of reserved short codes ready for new links
+	go handlers.RunCodePool(db.Ctx)
+
 	app := fiber.New(fiber.Config{
 		// Increase header size limits to prevent "Request Header Fields Too Large" errors
 		ReadBufferSize:  32768, // 32KB - increased for NextAuth JWT tokens
@@ -73,7 +80,9 @@ func main() {
 	// Public routes (no authentication required)
 	app.Get("/api/health", handlers.HealthCheck)
 	app.Get("/api/qrcode/:shortCode", handlers.GenerateQRCode)
+	app.Get("/:shortCode\\+", handlers.PreviewLink) // Destination preview, e.g. /abc123+
 	app.Get("/:shortCode", handlers.RedirectLink)
+	app.Post("/:shortCode", handlers.UnlockLink) // Password form for protected links
 
 	// Development-only authentication routes have been removed in favor of NextAuth session validation
 
@@ -84,10 +93,34 @@ func main() {
 	// User routes (require authentication)
 	user := app.Group("/api/user", middleware.NextAuthMiddleware())
 	user.Post("/shorten", handlers.ShortenLink) // Create shortened links (authenticated users only)
+	user.Post("/shorten/bulk", handlers.BulkShortenLinks) // Create many links from a JSON array or CSV upload
+	user.Get("/aliases/check", handlers.CheckAlias) // Alias availability (?alias=&domain=) with suggestions when taken
 	user.Get("/links", handlers.GetAllLinks) // Now returns user's own links or all if admin
+	user.Get("/links/search", handlers.SearchLinks) // Full-text search (?q=) over the user's links, or all if admin
+	user.Get("/links/expired", handlers.GetExpiredLinks) // Expired links, most recently expired first
+	user.Patch("/links/:shortCode", handlers.UpdateLink) // Edit destination, context or expiry (owner or admin)
+	user.Delete("/links/:shortCode", handlers.DeleteLink) // Hard-delete (?analytics=purge|archive)
+	user.Post("/links/:shortCode/deactivate", handlers.DeactivateLink) // Disable redirects without deleting
+	user.Post("/links/:shortCode/reactivate", handlers.ReactivateLink) // Re-enable a deactivated link
+	user.Post("/links/:shortCode/renew", handlers.RenewLink) // New expiry for an expired or expiring link
+	user.Get("/links/:shortCode/revisions", handlers.GetLinkRevisions) // Destination history, newest first
+	user.Post("/links/:shortCode/revisions/:revision/rollback", handlers.RollbackLink) // Restore an earlier destination
 	user.Get("/profile", handlers.GetUserProfile) // Full profile with stats
 	user.Put("/profile", handlers.UpdateProfile) // Update profile
 	user.Get("/stats", handlers.GetUserStats) // User statistics
+	user.Get("/utm-template", handlers.GetUTMTemplate) // UTM tags applied to all of the user's links
+	user.Put("/utm-template", handlers.UpdateUTMTemplate) // Replace the user's UTM template
+	user.Get("/fallback-url", handlers.GetFallbackURL) // Where expired links go without their own fallback
+	user.Put("/fallback-url", handlers.UpdateFallbackURL) // Set or remove (empty) the fallback URL
+	user.Get("/tags", handlers.GetTags) // User's tags with link and click counts
+	user.Post("/tags", handlers.CreateTag) // Create a tag
+	user.Patch("/tags/:id", handlers.RenameTag) // Rename a tag
+	user.Delete("/tags/:id", handlers.DeleteTag) // Delete a tag and remove it from all links
+	user.Get("/domains", handlers.GetDomains) // User's custom domains
+	user.Post("/domains", handlers.AddDomain) // Register a custom domain (returns the TXT record to create)
+	user.Post("/domains/:id/verify", handlers.VerifyDomain) // Check the domain's TXT record
+	user.Patch("/domains/:id", handlers.UpdateDomain) // Set the domain's fallback URL
+	user.Delete("/domains/:id", handlers.DeleteDomain) // Remove a domain without links
 
 	// Admin routes (require authentication + admin privileges + optional IP filtering)
 	admin := app.Group("/api/admin")
@@ -103,6 +136,9 @@ func main() {
 	admin.Get("/analytics/:shortCode", handlers.GetAnalytics) // Admin can see any link analytics
 	admin.Get("/users", handlers.ListUsers) // List all users
 	admin.Get("/users/:id", handlers.GetUserByID) // Get specific user details
+	admin.Get("/blocklist", handlers.ListBlockedDomains) // Destination domains links may not point to
+	admin.Post("/blocklist", handlers.BlockDomain) // Block a domain and its subdomains
+	admin.Delete("/blocklist/:domain", handlers.UnblockDomain) // Unblock a domain
 
 	// Start the server
 	log.Fatal(app.Listen(":3001")) // Running on port 3001
@@ -0,0 +1,5 @@
+-- +goose Down
+-- Revert link status and analytics archive
+
+DROP TABLE IF EXISTS analytics_archive;
+ALTER TABLE links DROP COLUMN IF EXISTS is_active;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Allow links to be deactivated without deleting them
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Analytics kept after a link is hard-deleted with the "archive" retention option
+CREATE TABLE IF NOT EXISTS analytics_archive (
+    id INTEGER PRIMARY KEY,
+    short_code VARCHAR(255) NOT NULL,
+    ip_address INET,
+    user_agent TEXT,
+    referrer TEXT,
+    country VARCHAR(255),
+    region VARCHAR(255),
+    city VARCHAR(255),
+    clicked_at TIMESTAMPTZ,
+    archived_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_archive_short_code ON analytics_archive(short_code);
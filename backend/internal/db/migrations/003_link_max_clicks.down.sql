@@ -0,0 +1,4 @@
+-- +goose Down
+-- Revert per-link click caps
+
+ALTER TABLE links DROP COLUMN IF EXISTS max_clicks;
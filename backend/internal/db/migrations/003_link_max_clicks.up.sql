@@ -0,0 +1,4 @@
+-- +goose Up
+-- Optional click cap per link (NULL means unlimited)
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS max_clicks INTEGER;
@@ -0,0 +1,4 @@
+-- +goose Down
+-- Revert password-protected links
+
+ALTER TABLE links DROP COLUMN IF EXISTS password_hash;
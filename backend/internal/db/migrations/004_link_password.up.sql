@@ -0,0 +1,4 @@
+-- +goose Up
+-- Optional bcrypt password hash for password-protected links
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS password_hash TEXT;
@@ -0,0 +1,4 @@
+-- +goose Down
+-- Revert per-link redirect status codes
+
+ALTER TABLE links DROP COLUMN IF EXISTS redirect_type;
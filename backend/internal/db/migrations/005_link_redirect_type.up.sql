@@ -0,0 +1,5 @@
+-- +goose Up
+-- Per-link redirect status code (NULL uses the server-wide default)
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS redirect_type SMALLINT
+    CHECK (redirect_type IN (301, 302, 307, 308));
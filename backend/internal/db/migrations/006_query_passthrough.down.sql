@@ -0,0 +1,6 @@
+-- +goose Down
+-- Revert query string passthrough
+
+ALTER TABLE analytics_archive DROP COLUMN IF EXISTS query_params;
+ALTER TABLE analytics DROP COLUMN IF EXISTS query_params;
+ALTER TABLE links DROP COLUMN IF EXISTS query_passthrough;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Per-link query string passthrough settings and the parameters forwarded on each click
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS query_passthrough JSONB;
+
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS query_params TEXT;
+ALTER TABLE analytics_archive ADD COLUMN IF NOT EXISTS query_params TEXT;
@@ -0,0 +1,8 @@
+-- +goose Down
+-- Revert UTM tags
+
+DROP INDEX IF EXISTS idx_analytics_utm_campaign;
+ALTER TABLE analytics_archive DROP COLUMN IF EXISTS utm_campaign;
+ALTER TABLE analytics DROP COLUMN IF EXISTS utm_campaign;
+ALTER TABLE users DROP COLUMN IF EXISTS utm_template;
+ALTER TABLE links DROP COLUMN IF EXISTS utm;
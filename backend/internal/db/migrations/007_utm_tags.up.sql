@@ -0,0 +1,10 @@
+-- +goose Up
+-- UTM tags stored per link and as a per-user template, and the campaign of each click
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS utm JSONB;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS utm_template JSONB;
+
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(255);
+ALTER TABLE analytics_archive ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_utm_campaign ON analytics(utm_campaign);
@@ -0,0 +1,4 @@
+-- +goose Down
+-- Revert conditional redirect rules
+
+ALTER TABLE links DROP COLUMN IF EXISTS redirect_rules;
@@ -0,0 +1,4 @@
+-- +goose Up
+-- Ordered conditional redirect rules per link
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS redirect_rules JSONB;
@@ -0,0 +1,6 @@
+-- +goose Down
+-- Revert A/B destinations
+
+ALTER TABLE analytics_archive DROP COLUMN IF EXISTS variant;
+ALTER TABLE analytics DROP COLUMN IF EXISTS variant;
+ALTER TABLE links DROP COLUMN IF EXISTS ab_test;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Weighted A/B destinations per link and the variant served on each click
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS ab_test JSONB;
+
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS variant VARCHAR(50);
+ALTER TABLE analytics_archive ADD COLUMN IF NOT EXISTS variant VARCHAR(50);
@@ -0,0 +1,6 @@
+-- +goose Down
+-- Revert scheduled activation
+
+ALTER TABLE links DROP COLUMN IF EXISTS prelaunch_message;
+ALTER TABLE links DROP COLUMN IF EXISTS prelaunch_url;
+ALTER TABLE links DROP COLUMN IF EXISTS active_from;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Scheduled activation: links that only start redirecting at active_from
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS active_from TIMESTAMPTZ;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS prelaunch_url TEXT;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS prelaunch_message VARCHAR(200);
@@ -0,0 +1,4 @@
+-- +goose Down
+-- Revert forced preview pages
+
+ALTER TABLE links DROP COLUMN IF EXISTS interstitial;
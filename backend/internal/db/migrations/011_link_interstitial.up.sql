@@ -0,0 +1,4 @@
+-- +goose Up
+-- Allow links to show the destination preview page before every visit
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS interstitial BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+-- +goose Down
+-- Remove the destination domain blocklist
+
+DROP TABLE IF EXISTS blocked_domains;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Admin-managed blocklist of destination domains and hosts
+
+CREATE TABLE IF NOT EXISTS blocked_domains (
+    id SERIAL PRIMARY KEY,
+    domain VARCHAR(253) UNIQUE NOT NULL,
+    reason TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
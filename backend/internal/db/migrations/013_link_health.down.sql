@@ -0,0 +1,4 @@
+-- +goose Down
+-- Remove destination health checks
+
+DROP TABLE IF EXISTS link_health;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Latest destination health check result for each link
+
+CREATE TABLE IF NOT EXISTS link_health (
+    link_id INTEGER PRIMARY KEY REFERENCES links(id) ON DELETE CASCADE,
+    status_code INTEGER,
+    latency_ms INTEGER,
+    error TEXT,
+    is_broken BOOLEAN NOT NULL DEFAULT FALSE,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    checked_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_health_broken ON link_health(link_id) WHERE is_broken;
@@ -0,0 +1,5 @@
+-- +goose Down
+-- Remove link tags
+
+DROP TABLE IF EXISTS link_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,6 @@
+-- +goose Down
+-- Remove link list indexes
+
+DROP INDEX IF EXISTS idx_links_user_id_created_at_id;
+DROP INDEX IF EXISTS idx_links_created_at_id;
+DROP INDEX IF EXISTS idx_analytics_short_code;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Indexes backing the paginated link list and its per-link click counts
+
+CREATE INDEX IF NOT EXISTS idx_analytics_short_code ON analytics(short_code);
+CREATE INDEX IF NOT EXISTS idx_links_created_at_id ON links(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_links_user_id_created_at_id ON links(user_id, created_at, id);
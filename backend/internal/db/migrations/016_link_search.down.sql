@@ -0,0 +1,5 @@
+-- +goose Down
+-- Remove link full-text search
+
+DROP INDEX IF EXISTS idx_links_search_vector;
+ALTER TABLE links DROP COLUMN IF EXISTS search_vector;
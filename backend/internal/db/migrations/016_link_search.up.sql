@@ -0,0 +1,11 @@
+-- +goose Up
+-- Full-text search over short code, destination, destination domain and context
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', short_code), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(substring(long_url from '^[a-zA-Z][a-zA-Z0-9+.-]*://([^/:?#]+)'), '')), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(context, '')), 'B') ||
+    setweight(to_tsvector('simple', regexp_replace(long_url, '[^a-zA-Z0-9]+', ' ', 'g')), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_links_search_vector ON links USING GIN(search_vector);
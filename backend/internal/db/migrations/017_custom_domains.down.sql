@@ -0,0 +1,11 @@
+-- +goose Down
+-- Remove custom domains. Restoring the short_code length fails while
+-- custom domain link keys longer than 255 characters exist.
+
+ALTER TABLE analytics_archive ALTER COLUMN short_code TYPE VARCHAR(255);
+ALTER TABLE analytics ALTER COLUMN short_code TYPE VARCHAR(255);
+ALTER TABLE links ALTER COLUMN short_code TYPE VARCHAR(255);
+
+DROP INDEX IF EXISTS idx_links_domain_id;
+ALTER TABLE links DROP COLUMN IF EXISTS domain_id;
+DROP TABLE IF EXISTS domains;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Custom branded domains. Links on a custom domain store "<hostname>/<code>"
+-- as their short_code, so the same code can exist once per domain while
+-- short_code stays the unique key analytics and caches refer to.
+
+CREATE TABLE IF NOT EXISTS domains (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hostname VARCHAR(253) NOT NULL,
+    verification_token VARCHAR(64) NOT NULL,
+    verified_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A hostname is only unique once verified, so an unverified claim cannot
+-- keep the real owner from registering it. Each user may claim a hostname once.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_domains_verified_hostname ON domains(hostname) WHERE verified_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_domains_user_hostname ON domains(user_id, hostname);
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS domain_id INTEGER REFERENCES domains(id);
+CREATE INDEX IF NOT EXISTS idx_links_domain_id ON links(domain_id);
+
+-- "<hostname>/<code>" keys are up to 253 + 1 + 50 characters
+ALTER TABLE links ALTER COLUMN short_code TYPE VARCHAR(320);
+ALTER TABLE analytics ALTER COLUMN short_code TYPE VARCHAR(320);
+ALTER TABLE analytics_archive ALTER COLUMN short_code TYPE VARCHAR(320);
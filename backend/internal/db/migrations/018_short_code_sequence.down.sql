@@ -0,0 +1,4 @@
+-- +goose Down
+-- Remove the short code sequence
+
+DROP SEQUENCE IF EXISTS short_code_seq;
@@ -0,0 +1,5 @@
+-- +goose Up
+-- Sequence behind the "sequence" short code strategy. It starts at 62^3 so
+-- base62 codes are at least four characters long.
+
+CREATE SEQUENCE IF NOT EXISTS short_code_seq START WITH 238328;
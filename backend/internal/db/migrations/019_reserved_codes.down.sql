@@ -0,0 +1,4 @@
+-- +goose Down
+-- Remove reserved short codes
+
+DROP TABLE IF EXISTS reserved_codes;
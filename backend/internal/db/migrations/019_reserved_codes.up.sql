@@ -0,0 +1,8 @@
+-- +goose Up
+-- Short codes generated in advance for the Redis code pool. A code is
+-- reserved here until a link is created with it, so it is handed out once.
+
+CREATE TABLE IF NOT EXISTS reserved_codes (
+    code VARCHAR(50) PRIMARY KEY,
+    reserved_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
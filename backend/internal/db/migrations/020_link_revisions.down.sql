@@ -0,0 +1,4 @@
+-- +goose Down
+-- Remove link revision history
+
+DROP TABLE IF EXISTS link_revisions;
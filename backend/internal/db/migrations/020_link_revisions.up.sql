@@ -0,0 +1,21 @@
+-- +goose Up
+-- History of link destinations. A revision is recorded when a link is created
+-- and whenever an edit or rollback changes its destination.
+
+CREATE TABLE IF NOT EXISTS link_revisions (
+    id SERIAL PRIMARY KEY,
+    link_id INTEGER NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    long_url TEXT NOT NULL,
+    redirect_rules JSONB,
+    ab_test JSONB,
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    rollback_of INTEGER,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (link_id, revision)
+);
+
+-- Existing links start with their current destination as revision 1
+INSERT INTO link_revisions (link_id, revision, long_url, redirect_rules, ab_test, changed_by, created_at)
+SELECT id, 1, long_url, redirect_rules, ab_test, user_id, created_at FROM links
+ON CONFLICT (link_id, revision) DO NOTHING;
@@ -0,0 +1,6 @@
+-- +goose Down
+-- Remove fallback destinations for expired links
+
+ALTER TABLE domains DROP COLUMN IF EXISTS fallback_url;
+ALTER TABLE users DROP COLUMN IF EXISTS fallback_url;
+ALTER TABLE links DROP COLUMN IF EXISTS expired_redirect_url;
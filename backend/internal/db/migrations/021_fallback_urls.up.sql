@@ -0,0 +1,7 @@
+-- +goose Up
+-- Where visitors of expired links go instead of the 410 page: the link's own
+-- expired_redirect_url, else its custom domain's or owner's fallback_url.
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS expired_redirect_url TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS fallback_url TEXT;
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS fallback_url TEXT;
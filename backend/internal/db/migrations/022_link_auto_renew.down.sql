@@ -0,0 +1,4 @@
+-- +goose Down
+-- Remove link auto-renewal
+
+ALTER TABLE links DROP COLUMN IF EXISTS auto_renew;
@@ -0,0 +1,5 @@
+-- +goose Up
+-- Links with auto_renew push their expiry back when clicked shortly before
+-- they expire
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS auto_renew BOOLEAN NOT NULL DEFAULT false;
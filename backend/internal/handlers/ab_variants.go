@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// How an A/B test assigns visitors to variants
+const (
+	abModeRandom = "random" // pick a variant on every click
+	abModeSticky = "sticky" // remember the visitor's variant in a cookie
+)
+
+const (
+	maxABVariants       = 10
+	variantCookieMaxAge = 30 * 24 * time.Hour
+)
+
+var validVariantName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,50}$`)
+
+// ABTest splits a link's traffic between weighted destinations
+type ABTest struct {
+	Mode     string    `json:"mode,omitempty"` // random (default) or sticky
+	Variants []Variant `json:"variants"`
+}
+
+// Variant is one destination of an A/B test. A variant receives
+// Weight / (sum of all weights) of the traffic.
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// validateABTest checks if the provided A/B test is valid
+func validateABTest(test *ABTest) error {
+	if test == nil {
+		return nil
+	}
+	if test.Mode != "" && test.Mode != abModeRandom && test.Mode != abModeSticky {
+		return fmt.Errorf("A/B test mode must be either 'random' or 'sticky'")
+	}
+	if len(test.Variants) < 2 || len(test.Variants) > maxABVariants {
+		return fmt.Errorf("an A/B test needs between 2 and %d variants", maxABVariants)
+	}
+
+	names := make(map[string]bool)
+	for _, variant := range test.Variants {
+		if !validVariantName.MatchString(variant.Name) {
+			return fmt.Errorf("variant names must be 1-50 letters, numbers, hyphens or underscores")
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("variant name %q is used more than once", variant.Name)
+		}
+		names[variant.Name] = true
+
+		if err := validateURL(variant.URL); err != nil {
+			return fmt.Errorf("variant %q: %v", variant.Name, err)
+		}
+		if variant.Weight < 1 || variant.Weight > 1000 {
+			return fmt.Errorf("variant %q: weight must be between 1 and 1000", variant.Name)
+		}
+	}
+	return nil
+}
+
+// variantCookieName returns the cookie holding a visitor's sticky variant
+func variantCookieName(shortCode string) string {
+	return "gochop_variant_" + publicCode(shortCode)
+}
+
+// find returns the variant with the given name
+func (test *ABTest) find(name string) *Variant {
+	for i := range test.Variants {
+		if test.Variants[i].Name == name {
+			return &test.Variants[i]
+		}
+	}
+	return nil
+}
+
+// pickWeighted chooses a variant at random in proportion to the weights
+func (test *ABTest) pickWeighted() *Variant {
+	total := 0
+	for _, variant := range test.Variants {
+		total += variant.Weight
+	}
+	n := rand.Intn(total)
+	for i := range test.Variants {
+		n -= test.Variants[i].Weight
+		if n < 0 {
+			return &test.Variants[i]
+		}
+	}
+	return &test.Variants[len(test.Variants)-1]
+}
+
+// pickVariant chooses the variant to serve. In sticky mode a visitor keeps
+// the variant named in their cookie for as long as it still exists.
+func (test *ABTest) pickVariant(c *fiber.Ctx, shortCode string) *Variant {
+	if test.Mode != abModeSticky {
+		return test.pickWeighted()
+	}
+
+	if variant := test.find(c.Cookies(variantCookieName(shortCode))); variant != nil {
+		return variant
+	}
+	variant := test.pickWeighted()
+	c.Cookie(&fiber.Cookie{
+		Name:     variantCookieName(shortCode),
+		Value:    variant.Name,
+		Path:     "/" + publicCode(shortCode),
+		Expires:  time.Now().Add(variantCookieMaxAge),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return variant
+}
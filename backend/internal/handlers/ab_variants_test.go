@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPickWeighted(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []Variant
+		want     map[string]float64 // expected share of traffic
+	}{
+		{"single", []Variant{{Name: "a", Weight: 1}}, map[string]float64{"a": 1}},
+		{"even", []Variant{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}}, map[string]float64{"a": 0.5, "b": 0.5}},
+		{"weighted", []Variant{{Name: "a", Weight: 3}, {Name: "b", Weight: 1}}, map[string]float64{"a": 0.75, "b": 0.25}},
+		{"extremes", []Variant{{Name: "a", Weight: 1000}, {Name: "b", Weight: 1}, {Name: "c", Weight: 999}}, map[string]float64{"a": 0.5, "b": 0.0005, "c": 0.4995}},
+	}
+	const picks = 20000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := &ABTest{Variants: tt.variants}
+			counts := make(map[string]int)
+			for i := 0; i < picks; i++ {
+				counts[test.pickWeighted().Name]++
+			}
+			for name, count := range counts {
+				if _, ok := tt.want[name]; !ok {
+					t.Errorf("variant %q served %d times, want never", name, count)
+				}
+			}
+			for name, share := range tt.want {
+				got := float64(counts[name]) / picks
+				if math.Abs(got-share) > 0.03 {
+					t.Errorf("variant %q got %.3f of traffic, want %.3f", name, got, share)
+				}
+			}
+		})
+	}
+}
+
+func TestABTestFind(t *testing.T) {
+	test := &ABTest{Variants: []Variant{{Name: "a"}, {Name: "b"}}}
+	if v := test.find("b"); v == nil || v.Name != "b" {
+		t.Errorf("find(b) = %v", v)
+	}
+	if v := test.find("gone"); v != nil {
+		t.Errorf("find(gone) = %v, want nil", v)
+	}
+}
+
+func TestValidateABTestShape(t *testing.T) {
+	// Every case fails before a variant's destination would be checked
+	// against the URL policy
+	tests := []struct {
+		name string
+		test *ABTest
+	}{
+		{"unknown mode", &ABTest{Mode: "round-robin", Variants: []Variant{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}}}},
+		{"one variant", &ABTest{Variants: []Variant{{Name: "a", Weight: 1}}}},
+		{"bad name", &ABTest{Variants: []Variant{{Name: "a b", Weight: 1}, {Name: "b", Weight: 1}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateABTest(tt.test); err == nil {
+				t.Error("validateABTest = nil, want an error")
+			}
+		})
+	}
+	if err := validateABTest(nil); err != nil {
+		t.Errorf("validateABTest(nil) = %v", err)
+	}
+}
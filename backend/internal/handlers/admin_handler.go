@@ -1,32 +1,122 @@
 package handlers
 
 import (
+	"encoding/json"
 	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
 )
 
 // LinkInfo represents the structure for link information
 type LinkInfo struct {
-	ID        int       `json:"id"`
-	ShortCode string    `json:"short_code"`
-	LongURL   string    `json:"long_url"`
-	Context   string    `json:"context"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
-	ClickCount int      `json:"click_count"`
-	UserID    string    `json:"user_id"`
+	ID                int                  `json:"id"`
+	ShortCode         string               `json:"short_code"`
+	LongURL           string               `json:"long_url"`
+	Context           string               `json:"context"`
+	CreatedAt         time.Time            `json:"created_at"`
+	ExpiresAt         *time.Time           `json:"expires_at"`
+	ClickCount        int                  `json:"click_count"`
+	UserID            string               `json:"user_id"`
+	IsActive          bool                 `json:"is_active"`
+	MaxClicks         *int                 `json:"max_clicks"`
+	RemainingClicks   *int                 `json:"remaining_clicks,omitempty"`
+	PasswordProtected bool                 `json:"password_protected"`
+	RedirectType      *int                 `json:"redirect_type"`
+	QueryPassthrough  *QueryPassthrough    `json:"query_passthrough"`
+	UTM               *services.UTMParams  `json:"utm"`
+	Rules             []RedirectRule       `json:"rules"`
+	ABTest            *ABTest              `json:"ab_test"`
+	ActiveFrom        *time.Time           `json:"active_from"`
+	PrelaunchURL      *string              `json:"prelaunch_url"`
+	PrelaunchMessage  *string              `json:"prelaunch_message"`
+	Interstitial      bool                 `json:"interstitial"`
+	Health            *services.LinkHealth `json:"health"`
+	Tags              []string             `json:"tags"`
+	Domain            *string              `json:"domain"`
+	ShortURL          string               `json:"short_url"`
+
+	ExpiredRedirectURL *string `json:"expired_redirect_url"`
+	AutoRenew          bool    `json:"auto_renew"`
+
+	// key is the short_code the link is stored under (see linkKey)
+	key string
+}
+
+// linkInfoColumns selects the LinkInfo fields from "links l"; read them with scanLinkInfo
+const linkInfoColumns = `
+	l.id, l.short_code, l.long_url, COALESCE(l.context, ''), l.created_at, l.expires_at,
+	(SELECT COUNT(*) FROM analytics a WHERE a.short_code = l.short_code) as click_count,
+	COALESCE(l.user_id::text, ''), l.is_active, l.max_clicks,
+	l.password_hash IS NOT NULL as password_protected, l.redirect_type, l.query_passthrough, l.utm,
+	l.redirect_rules, l.ab_test, l.active_from, l.prelaunch_url, l.prelaunch_message,
+	l.interstitial,
+	(SELECT row_to_json(h) FROM link_health h WHERE h.link_id = l.id) as health,
+	ARRAY(SELECT t.name FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = l.id ORDER BY t.name) as tags,
+	(SELECT d.hostname FROM domains d WHERE d.id = l.domain_id) as domain,
+	l.expired_redirect_url, l.auto_renew
+`
+
+// scanLinkInfo reads a row selected with linkInfoColumns
+func scanLinkInfo(row pgx.Row) (*LinkInfo, error) {
+	var link LinkInfo
+	var passthrough, utm, rules, abTest, health []byte
+	err := row.Scan(&link.ID, &link.ShortCode, &link.LongURL, &link.Context, &link.CreatedAt, &link.ExpiresAt,
+		&link.ClickCount, &link.UserID, &link.IsActive, &link.MaxClicks,
+		&link.PasswordProtected, &link.RedirectType, &passthrough, &utm,
+		&rules, &abTest, &link.ActiveFrom, &link.PrelaunchURL, &link.PrelaunchMessage,
+		&link.Interstitial, &health, &link.Tags, &link.Domain,
+		&link.ExpiredRedirectURL, &link.AutoRenew)
+	if err != nil {
+		return nil, err
+	}
+	if len(passthrough) > 0 {
+		json.Unmarshal(passthrough, &link.QueryPassthrough)
+	}
+	link.UTM = decodeUTM(utm)
+	if len(rules) > 0 {
+		json.Unmarshal(rules, &link.Rules)
+	}
+	if len(abTest) > 0 {
+		json.Unmarshal(abTest, &link.ABTest)
+	}
+	if len(health) > 0 {
+		json.Unmarshal(health, &link.Health)
+	}
+	// Links on a custom domain are stored as "<hostname>/<code>"
+	link.key = link.ShortCode
+	link.ShortURL = shortURLFor(link.ShortCode)
+	link.ShortCode = publicCode(link.ShortCode)
+	return &link, nil
 }
 
 // AnalyticsInfo represents analytics data for a specific link
 type AnalyticsInfo struct {
-	ShortCode       string                 `json:"short_code"`
-	TotalClicks     int                    `json:"total_clicks"`
-	ClicksByDate    []DailyClickData       `json:"clicks_by_date"`
-	TopReferrers    []ReferrerData         `json:"top_referrers"`
-	TopUserAgents   []UserAgentData        `json:"top_user_agents"`
-	GeographicData  []GeographicData       `json:"geographic_data"`
+	ShortCode        string           `json:"short_code"`
+	TotalClicks      int              `json:"total_clicks"`
+	ClicksByDate     []DailyClickData `json:"clicks_by_date"`
+	TopReferrers     []ReferrerData   `json:"top_referrers"`
+	TopUserAgents    []UserAgentData  `json:"top_user_agents"`
+	GeographicData   []GeographicData `json:"geographic_data"`
+	ClicksByCampaign []CampaignData   `json:"clicks_by_campaign"`
+	ClicksByVariant  []VariantData    `json:"clicks_by_variant"`
+
+	// ClicksByRevision splits clicks by the periods between destination changes
+	ClicksByRevision []RevisionClickData `json:"clicks_by_revision"`
+}
+
+// VariantData represents click statistics for an A/B test variant
+type VariantData struct {
+	Variant string `json:"variant"`
+	Clicks  int    `json:"clicks"`
+}
+
+// CampaignData represents click statistics for a UTM campaign
+type CampaignData struct {
+	Campaign string `json:"campaign"`
+	Clicks   int    `json:"clicks"`
 }
 
 // DailyClickData represents click data for a specific date
@@ -55,8 +145,47 @@ type GeographicData struct {
 	Clicks  int    `json:"clicks"`
 }
 
-// GetAllLinks fetches all links with their click counts for the authenticated user
+// canAccessLink reports whether the link exists and is visible to the given user.
+// Admins can access any link, regular users only their own.
+func canAccessLink(shortCode, userID string, isAdmin bool) (bool, error) {
+	var exists bool
+	var checkQuery string
+	var args []interface{}
+
+	if isAdmin {
+		checkQuery = "SELECT EXISTS(SELECT 1 FROM links WHERE short_code = $1)"
+		args = []interface{}{shortCode}
+	} else {
+		checkQuery = "SELECT EXISTS(SELECT 1 FROM links WHERE short_code = $1 AND user_id = $2)"
+		args = []interface{}{shortCode, userID}
+	}
+
+	err := db.DB.QueryRow(db.Ctx, checkQuery, args...).Scan(&exists)
+	return exists, err
+}
+
+// getLinkInfo fetches a single link with its click count
+func getLinkInfo(shortCode string) (*LinkInfo, error) {
+	query := `SELECT ` + linkInfoColumns + ` FROM links l WHERE l.short_code = $1`
+	link, err := scanLinkInfo(db.DB.QueryRow(db.Ctx, query, shortCode))
+	if err != nil {
+		return nil, err
+	}
+	fillRemainingClicks([]*LinkInfo{link})
+	return link, nil
+}
+
+// GetAllLinks returns one page of links with their click counts for the
+// authenticated user, or of all links for admins. See parseLinkListQuery for
+// the sort and filter parameters; pass next_cursor back as ?cursor= to fetch
+// the following page.
 func GetAllLinks(c *fiber.Ctx) error {
+	return listLinks(c, linkListOptions{})
+}
+
+// listLinks responds with one page of the links visible to the authenticated
+// user, as GetAllLinks describes, with the given fixed options
+func listLinks(c *fiber.Ctx, opts linkListOptions) error {
 	// Get user ID from context (set by NextAuth middleware)
 	userID, ok := c.Locals("userID").(string)
 	if !ok {
@@ -67,34 +196,22 @@ func GetAllLinks(c *fiber.Ctx) error {
 
 	// Check if user is admin - if so, return all links, otherwise filter by user
 	isAdmin, _ := c.Locals("isAdmin").(bool)
-	
-	var query string
-	var args []interface{}
-	
-	if isAdmin {
-		query = `
-			SELECT l.id, l.short_code, l.long_url, l.context, l.created_at, l.expires_at, 
-				   COALESCE(COUNT(a.id), 0) as click_count, l.user_id
-			FROM links l
-			LEFT JOIN analytics a ON l.short_code = a.short_code
-			GROUP BY l.id, l.short_code, l.long_url, l.context, l.created_at, l.expires_at, l.user_id
-			ORDER BY l.created_at DESC
-		`
-		args = []interface{}{}
-	} else {
-		query = `
-			SELECT l.id, l.short_code, l.long_url, l.context, l.created_at, l.expires_at, 
-				   COALESCE(COUNT(a.id), 0) as click_count, l.user_id
-			FROM links l
-			LEFT JOIN analytics a ON l.short_code = a.short_code
-			WHERE l.user_id = $1
-			GROUP BY l.id, l.short_code, l.long_url, l.context, l.created_at, l.expires_at, l.user_id
-			ORDER BY l.created_at DESC
-		`
-		args = []interface{}{userID}
+
+	q, err := parseLinkListQuery(c, userID, isAdmin, opts)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var total int
+	if err := db.DB.QueryRow(db.Ctx, q.countSQL(), q.args...).Scan(&total); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch links",
+		})
 	}
 
-	rows, err := db.DB.Query(db.Ctx, query, args...)
+	rows, err := db.DB.Query(db.Ctx, q.pageSQL(), q.args...)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not fetch links",
@@ -102,24 +219,39 @@ func GetAllLinks(c *fiber.Ctx) error {
 	}
 	defer rows.Close()
 
-	var links []LinkInfo
+	links := []LinkInfo{}
 	for rows.Next() {
-		var link LinkInfo
-		err := rows.Scan(&link.ID, &link.ShortCode, &link.LongURL, &link.Context, &link.CreatedAt, &link.ExpiresAt, &link.ClickCount, &link.UserID)
+		link, err := scanLinkInfo(rows)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Could not scan link data",
 			})
 		}
-		links = append(links, link)
+		links = append(links, *link)
 	}
 
-	return c.JSON(links)
+	var nextCursor *string
+	if len(links) > q.limit {
+		links = links[:q.limit]
+		cursor := cursorAfter(&links[len(links)-1], q.sort).encode()
+		nextCursor = &cursor
+	}
+	page := make([]*LinkInfo, len(links))
+	for i := range links {
+		page[i] = &links[i]
+	}
+	fillRemainingClicks(page)
+
+	return c.JSON(fiber.Map{
+		"links":       links,
+		"next_cursor": nextCursor,
+		"total":       total,
+	})
 }
 
 // GetAnalytics provides comprehensive analytics data for a specific link
 func GetAnalytics(c *fiber.Ctx) error {
-	shortCode := c.Params("shortCode")
+	shortCode := resolveAPILinkKey(c)
 	
 	// Get user ID from context (set by NextAuth middleware)
 	userID, ok := c.Locals("userID").(string)
@@ -133,22 +265,7 @@ func GetAnalytics(c *fiber.Ctx) error {
 	isAdmin, _ := c.Locals("isAdmin").(bool)
 
 	// Check if the link exists and user has access to it
-	var exists bool
-	var linkUserID string
-	var checkQuery string
-	var args []interface{}
-	
-	if isAdmin {
-		// Admin can view any link
-		checkQuery = "SELECT EXISTS(SELECT 1 FROM links WHERE short_code = $1), COALESCE((SELECT user_id FROM links WHERE short_code = $1), '')"
-		args = []interface{}{shortCode, shortCode}
-	} else {
-		// Regular user can only view their own links
-		checkQuery = "SELECT EXISTS(SELECT 1 FROM links WHERE short_code = $1 AND user_id = $2), COALESCE((SELECT user_id FROM links WHERE short_code = $1), '')"
-		args = []interface{}{shortCode, userID, shortCode}
-	}
-	
-	err := db.DB.QueryRow(db.Ctx, checkQuery, args...).Scan(&exists, &linkUserID)
+	exists, err := canAccessLink(shortCode, userID, isAdmin)
 	if err != nil || !exists {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Link not found or access denied",
@@ -156,7 +273,7 @@ func GetAnalytics(c *fiber.Ctx) error {
 	}
 
 	analytics := AnalyticsInfo{
-		ShortCode: shortCode,
+		ShortCode: publicCode(shortCode),
 	}
 
 	// Get total clicks
@@ -252,5 +369,51 @@ func GetAnalytics(c *fiber.Ctx) error {
 		}
 	}
 
+	// Get clicks by UTM campaign
+	campaignQuery := `
+		SELECT COALESCE(utm_campaign, 'None') as campaign, COUNT(*) as clicks
+		FROM analytics
+		WHERE short_code = $1
+		GROUP BY utm_campaign
+		ORDER BY clicks DESC
+		LIMIT 20
+	`
+	rows, err = db.DB.Query(db.Ctx, campaignQuery, shortCode)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var campaignData CampaignData
+			err := rows.Scan(&campaignData.Campaign, &campaignData.Clicks)
+			if err == nil {
+				analytics.ClicksByCampaign = append(analytics.ClicksByCampaign, campaignData)
+			}
+		}
+	}
+
+	// Get clicks by A/B test variant
+	variantQuery := `
+		SELECT variant, COUNT(*) as clicks
+		FROM analytics
+		WHERE short_code = $1 AND variant IS NOT NULL
+		GROUP BY variant
+		ORDER BY clicks DESC
+	`
+	rows, err = db.DB.Query(db.Ctx, variantQuery, shortCode)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var variantData VariantData
+			err := rows.Scan(&variantData.Variant, &variantData.Clicks)
+			if err == nil {
+				analytics.ClicksByVariant = append(analytics.ClicksByVariant, variantData)
+			}
+		}
+	}
+
+	// Get clicks by revision period
+	if periods, err := getClicksByRevision(shortCode); err == nil {
+		analytics.ClicksByRevision = periods
+	}
+
 	return c.JSON(analytics)
 } 
\ No newline at end of file
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxAliasSuggestions caps how many alternatives are offered for a taken alias
+const maxAliasSuggestions = 5
+
+// aliasSynonyms maps common alias words to alternatives
+var aliasSynonyms = map[string][]string{
+	"sale":     {"deal", "offer"},
+	"deal":     {"offer", "sale"},
+	"offer":    {"deal", "promo"},
+	"promo":    {"offer", "deal"},
+	"launch":   {"release", "debut"},
+	"release":  {"launch"},
+	"event":    {"meetup", "live"},
+	"docs":     {"guide", "help"},
+	"guide":    {"docs", "howto"},
+	"blog":     {"news", "post"},
+	"news":     {"updates", "blog"},
+	"signup":   {"join", "register"},
+	"join":     {"signup"},
+	"register": {"signup"},
+	"shop":     {"store"},
+	"store":    {"shop"},
+	"jobs":     {"careers", "hiring"},
+	"careers":  {"jobs"},
+	"contact":  {"hello", "reach"},
+	"free":     {"gratis", "bonus"},
+}
+
+var aliasWordSeparator = regexp.MustCompile(`[-_]`)
+
+// aliasTakenError is returned when inserting a link whose custom alias is
+// already used. It carries available alternatives.
+type aliasTakenError struct {
+	Suggestions []string
+}
+
+func (e *aliasTakenError) Error() string {
+	return "Custom alias is already taken."
+}
+
+// aliasCandidates returns alternatives for an alias in order of preference:
+// numbered, date-stamped, synonym and prefixed variants
+func aliasCandidates(alias string, now time.Time) []string {
+	year := now.Format("2006")
+	month := strings.ToLower(now.Format("Jan06"))
+
+	candidates := []string{alias + "-2", alias + "-" + year}
+
+	// Swap each word that has synonyms, keeping the original separators
+	words := aliasWordSeparator.Split(alias, -1)
+	separators := aliasWordSeparator.FindAllString(alias, -1)
+	for i, word := range words {
+		for _, synonym := range aliasSynonyms[strings.ToLower(word)] {
+			var b strings.Builder
+			for j, w := range words {
+				if j == i {
+					w = synonym
+				}
+				b.WriteString(w)
+				if j < len(separators) {
+					b.WriteString(separators[j])
+				}
+			}
+			candidates = append(candidates, b.String())
+		}
+	}
+
+	return append(candidates, alias+"-"+month, "get-"+alias, alias+"-3", "my-"+alias, alias+"-"+now.Format("20060102"))
+}
+
+// validAliasCandidates returns the distinct alternatives for an alias that
+// are themselves valid aliases, in order of preference
+func validAliasCandidates(alias string, now time.Time) []string {
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, candidate := range aliasCandidates(alias, now) {
+		if seen[candidate] || validateAlias(candidate) != nil {
+			continue
+		}
+		seen[candidate] = true
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// suggestAliases returns up to maxAliasSuggestions valid aliases that are
+// free on the given domain
+func suggestAliases(hostname, alias string) ([]string, error) {
+	candidates := validAliasCandidates(alias, time.Now())
+	keys := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		keys[i] = linkKey(hostname, candidate)
+	}
+
+	rows, err := db.DB.Query(db.Ctx, "SELECT short_code FROM links WHERE short_code = ANY($1)", keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		taken[key] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	suggestions := []string{}
+	for i, candidate := range candidates {
+		if !taken[keys[i]] && len(suggestions) < maxAliasSuggestions {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	return suggestions, nil
+}
+
+// CheckAlias reports whether ?alias= is valid and free, on ?domain= if given,
+// and suggests alternatives when it is not. Meant for checking as the user types.
+func CheckAlias(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	alias := strings.TrimSpace(c.Query("alias"))
+	if alias == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "alias is required",
+		})
+	}
+
+	var hostname string
+	if domain := c.Query("domain"); domain != "" {
+		hostname = services.NormalizeDomain(domain)
+		_, err := domainService.GetVerifiedDomainID(db.Ctx, userID, hostname)
+		if errors.Is(err, services.ErrDomainNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Domain is not one of your verified domains.",
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+		}
+	}
+
+	response := fiber.Map{
+		"alias":       alias,
+		"available":   false,
+		"suggestions": []string{},
+	}
+
+	if err := validateAlias(alias); err != nil {
+		response["reason"] = err.Error()
+		// Reserved words are well-formed, so alternatives still make sense
+		if !isReservedAlias(alias) {
+			return c.JSON(response)
+		}
+	} else {
+		taken, err := isShortCodeTaken(linkKey(hostname, alias))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+		}
+		if !taken {
+			response["available"] = true
+			return c.JSON(response)
+		}
+		response["reason"] = fmt.Sprintf("alias '%s' is already taken", alias)
+	}
+
+	suggestions, err := suggestAliases(hostname, alias)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	response["suggestions"] = suggestions
+	return c.JSON(response)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAliasCandidates(t *testing.T) {
+	now := time.Date(2025, time.March, 7, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		alias string
+		want  []string
+	}{
+		{
+			"no synonyms",
+			"acme",
+			[]string{"acme-2", "acme-2025", "acme-mar25", "get-acme", "acme-3", "my-acme", "acme-20250307"},
+		},
+		{
+			"synonyms keep separators",
+			"summer_sale-x",
+			[]string{"summer_sale-x-2", "summer_sale-x-2025", "summer_deal-x", "summer_offer-x",
+				"summer_sale-x-mar25", "get-summer_sale-x", "summer_sale-x-3", "my-summer_sale-x", "summer_sale-x-20250307"},
+		},
+		{
+			"synonyms are case-insensitive",
+			"Shop",
+			[]string{"Shop-2", "Shop-2025", "store", "Shop-mar25", "get-Shop", "Shop-3", "my-Shop", "Shop-20250307"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aliasCandidates(tt.alias, now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("aliasCandidates(%q) =\n%v\nwant\n%v", tt.alias, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidAliasCandidates(t *testing.T) {
+	now := time.Date(2025, time.March, 7, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		alias string
+		want  []string
+	}{
+		{
+			// "docs" swaps to "guide" and "help", but "help" is reserved
+			"drops reserved",
+			"docs",
+			[]string{"docs-2", "docs-2025", "guide", "docs-mar25", "get-docs", "docs-3", "my-docs", "docs-20250307"},
+		},
+		{
+			"drops too long",
+			strings.Repeat("a", 48),
+			[]string{strings.Repeat("a", 48) + "-2", strings.Repeat("a", 48) + "-3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validAliasCandidates(tt.alias, now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validAliasCandidates(%q) =\n%v\nwant\n%v", tt.alias, got, tt.want)
+			}
+		})
+	}
+}
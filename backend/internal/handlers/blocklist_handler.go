@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BlockDomainRequest defines the body for adding a domain to the blocklist
+type BlockDomainRequest struct {
+	Domain string `json:"domain"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ListBlockedDomains returns the destination blocklist (admin only)
+func ListBlockedDomains(c *fiber.Ctx) error {
+	domains, err := blocklistService.List(db.Ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve blocklist",
+		})
+	}
+
+	return c.JSON(domains)
+}
+
+// BlockDomain adds a domain to the blocklist (admin only). Subdomains of a
+// blocked domain are blocked as well. Existing links are not affected.
+func BlockDomain(c *fiber.Ctx) error {
+	req := new(BlockDomainRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	userID, _ := c.Locals("userID").(string)
+	blocked, err := blocklistService.Add(db.Ctx, req.Domain, req.Reason, userID)
+	if errors.Is(err, services.ErrInvalidDomain) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A valid domain or host name is required",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update blocklist",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(blocked)
+}
+
+// UnblockDomain removes a domain from the blocklist (admin only)
+func UnblockDomain(c *fiber.Ctx) error {
+	removed, err := blocklistService.Remove(db.Ctx, c.Params("domain"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update blocklist",
+		})
+	}
+	if !removed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Domain is not blocked",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Domain removed from blocklist",
+	})
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AddDomainRequest defines the body for registering a custom domain
+type AddDomainRequest struct {
+	Hostname string `json:"hostname"`
+}
+
+// UpdateDomainRequest defines the body for changing a custom domain's
+// settings; an empty fallback_url removes it
+type UpdateDomainRequest struct {
+	FallbackURL *string `json:"fallback_url"`
+}
+
+// domainError responds with the HTTP error for a domain service error
+func domainError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrDomainExists), errors.Is(err, services.ErrDomainInUse):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrDomainNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Domain not found",
+		})
+	case errors.Is(err, services.ErrDomainNotVerified):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not update domain",
+	})
+}
+
+// GetDomains lists the current user's custom domains
+func GetDomains(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	domains, err := domainService.ListDomains(db.Ctx, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve domains",
+		})
+	}
+
+	return c.JSON(domains)
+}
+
+// AddDomain registers a custom domain for the current user. The response
+// carries the TXT record to create before calling VerifyDomain.
+func AddDomain(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	req := new(AddDomainRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	hostname, err := services.NormalizeHostname(req.Hostname)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A valid hostname such as links.example.com is required",
+		})
+	}
+	if base, err := url.Parse(getBaseURL()); err == nil && services.NormalizeDomain(base.Hostname()) == hostname {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "This hostname is the default short link domain",
+		})
+	}
+
+	domain, err := domainService.AddDomain(db.Ctx, userID, hostname)
+	if err != nil {
+		return domainError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(domain)
+}
+
+// VerifyDomain checks the DNS TXT record of one of the current user's domains
+func VerifyDomain(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	domainID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid domain ID",
+		})
+	}
+
+	domain, err := domainService.VerifyDomain(db.Ctx, userID, domainID)
+	if err != nil {
+		return domainError(c, err)
+	}
+
+	return c.JSON(domain)
+}
+
+// UpdateDomain changes the fallback URL of one of the current user's domains
+func UpdateDomain(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	domainID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid domain ID",
+		})
+	}
+
+	req := new(UpdateDomainRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+	if req.FallbackURL == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "fallback_url is required",
+		})
+	}
+	if *req.FallbackURL != "" {
+		if err := validateURL(*req.FallbackURL); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	domain, err := domainService.SetFallbackURL(db.Ctx, userID, domainID, *req.FallbackURL)
+	if err != nil {
+		return domainError(c, err)
+	}
+
+	return c.JSON(domain)
+}
+
+// DeleteDomain removes one of the current user's domains if it has no links
+func DeleteDomain(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	domainID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid domain ID",
+		})
+	}
+
+	if err := domainService.DeleteDomain(db.Ctx, userID, domainID); err != nil {
+		return domainError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Domain deleted successfully",
+	})
+}
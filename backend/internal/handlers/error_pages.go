@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"embed"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Error pages shown to visitors of links that cannot be followed
+const (
+	pageNotFound = "not_found"
+	pageExpired  = "expired"
+	pageDisabled = "disabled"
+)
+
+//go:embed error_pages/*.html
+var embeddedErrorPages embed.FS
+
+var (
+	errorPagesOnce sync.Once
+	errorPages     map[string]*template.Template
+)
+
+// loadErrorPages parses the embedded error pages. A page is replaced by
+// <name>.html from ERROR_PAGES_DIR when that file exists and parses.
+func loadErrorPages() {
+	errorPages = make(map[string]*template.Template)
+	dir := os.Getenv("ERROR_PAGES_DIR")
+	for _, name := range []string{pageNotFound, pageExpired, pageDisabled} {
+		file := name + ".html"
+		if dir != "" {
+			if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+				page, err := template.ParseFiles(filepath.Join(dir, file))
+				if err == nil {
+					errorPages[name] = page
+					continue
+				}
+				log.Printf("Ignoring error page override %s: %v", file, err)
+			}
+		}
+		errorPages[name] = template.Must(template.ParseFS(embeddedErrorPages, "error_pages/"+file))
+	}
+}
+
+// renderErrorPage answers with the named error page, or with a JSON error for
+// clients that prefer JSON over HTML, such as API clients
+func renderErrorPage(c *fiber.Ctx, status int, page, message string) error {
+	c.Status(status)
+	c.Set("Cache-Control", "no-store")
+	if c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextHTML) != fiber.MIMETextHTML {
+		return c.JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	errorPagesOnce.Do(loadErrorPages)
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return errorPages[page].Execute(c.Response().BodyWriter(), fiber.Map{
+		"Status":  status,
+		"Message": message,
+		"HomeURL": getBaseURL(),
+	})
+}
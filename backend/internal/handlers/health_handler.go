@@ -26,14 +26,14 @@ func HealthCheck(c *fiber.Ctx) error {
 
 	// Check Redis connection
 	redisStatus := "healthy"
-	if db.RedisClient == nil {
+	if db.RDB == nil {
 		redisStatus = "unhealthy"
 	} else {
 		// Try to ping Redis
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		
-		if err := db.RedisClient.Ping(ctx).Err(); err != nil {
+		if err := db.RDB.Ping(ctx).Err(); err != nil {
 			redisStatus = "unhealthy"
 		}
 	}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+// maxBulkLinks caps how many links a single bulk request may create
+const maxBulkLinks = 500
+
+// BulkShortenResult is the outcome for one row of a bulk request. Row numbers
+// start at 1 and match the JSON array index or the CSV data row.
+type BulkShortenResult struct {
+	Row       int        `json:"row"`
+	ShortURL  string     `json:"short_url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+
+	// Suggestions lists available alternatives when the row's alias is taken
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// parseBulkCSV reads rows of long_url, alias, context, expiry. A header row is
+// skipped if present. The expiry column takes an RFC 3339 timestamp, a
+// duration such as "30d", or "never".
+func parseBulkCSV(r io.Reader) ([]ShortenRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var requests []ShortenRequest
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "long_url") {
+			continue
+		}
+
+		field := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		req := ShortenRequest{
+			LongURL: field(0),
+			Alias:   field(1),
+			Context: field(2),
+		}
+		if expiry := field(3); expiry != "" {
+			if t, err := time.Parse(time.RFC3339, expiry); err == nil {
+				req.ExpiresAt = &t
+			} else {
+				req.ExpiresIn = expiry
+			}
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// BulkShortenLinks creates many links at once from either a JSON array of
+// ShortenRequest objects or a multipart CSV upload in the "file" field.
+// Valid rows are inserted in a single transaction; every row gets a result
+// with either its short URL or the reason it was rejected.
+func BulkShortenLinks(c *fiber.Ctx) error {
+	// Get user ID from context (authentication is required for this endpoint)
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	var requests []ShortenRequest
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "CSV file is required in the 'file' field",
+			})
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Cannot read uploaded file",
+			})
+		}
+		defer file.Close()
+
+		requests, err = parseBulkCSV(file)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	} else if err := c.BodyParser(&requests); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if len(requests) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No links to create",
+		})
+	}
+	if len(requests) > maxBulkLinks {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("A bulk request can create at most %d links", maxBulkLinks),
+		})
+	}
+
+	// Validate every row first so one bad row does not block the others
+	results := make([]BulkShortenResult, len(requests))
+	links := make([]*newLink, len(requests))
+	for i := range requests {
+		results[i].Row = i + 1
+		link, err := prepareLink(&requests[i], userID)
+		if err != nil {
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				results[i].Error = fiberErr.Message
+			} else {
+				results[i].Error = err.Error()
+			}
+			continue
+		}
+		links[i] = link
+	}
+
+	// If the batch is not saved, the codes generated for it are released.
+	// This is deferred before the rollback so it runs after it.
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		var codes []string
+		for _, link := range links {
+			if link != nil {
+				codes = append(codes, link.generatedCodes()...)
+			}
+		}
+		releaseShortCodes(codes)
+	}()
+
+	// Insert the valid rows in one transaction. Aliases are claimed by the
+	// insert, so taken ones (or ones repeated within the upload) become row
+	// errors instead of aborting the whole batch.
+	tx, err := db.DB.Begin(db.Ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	defer tx.Rollback(db.Ctx)
+
+	batch := &pgx.Batch{}
+	var queued []int
+	for i, link := range links {
+		if link == nil {
+			continue
+		}
+		batch.Queue(insertLinkSQL, link.insertArgs(userID)...)
+		queued = append(queued, i)
+	}
+
+	inserted := make([]bool, len(requests))
+	var retry []int
+	if len(queued) > 0 {
+		br := tx.SendBatch(db.Ctx, batch)
+		for _, i := range queued {
+			tag, err := br.Exec()
+			if err != nil {
+				br.Close()
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Could not save links to database.",
+				})
+			}
+			if tag.RowsAffected() == 0 {
+				if links[i].Alias == "" {
+					retry = append(retry, i)
+					continue
+				}
+				results[i].Error = (&aliasTakenError{}).Error()
+				results[i].Suggestions, _ = suggestAliases(links[i].Hostname, links[i].Alias)
+				continue
+			}
+			inserted[i] = true
+		}
+		if err := br.Close(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not save links to database.",
+			})
+		}
+	}
+
+	// Generated codes that were claimed in the meantime are replaced
+	for _, i := range retry {
+		err := links[i].insert(tx, userID)
+		if errors.Is(err, services.ErrShortCodeExhausted) {
+			results[i].Error = err.Error()
+			continue
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not save links to database.",
+			})
+		}
+		inserted[i] = true
+	}
+
+	// Codes taken from the pool are no longer reserved once in use
+	var claimed []string
+	for i, ok := range inserted {
+		if ok && links[i].DomainID == 0 {
+			claimed = append(claimed, links[i].ShortCode)
+		}
+	}
+	if len(claimed) > 0 {
+		if _, err := tx.Exec(db.Ctx, releaseReservationsSQL, claimed); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not save links to database.",
+			})
+		}
+	}
+
+	// Every new link starts with its destination as revision 1
+	var newCodes []string
+	for i, ok := range inserted {
+		if ok {
+			newCodes = append(newCodes, links[i].ShortCode)
+		}
+	}
+	if err := recordRevisions(tx, newCodes, userID, nil); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not save links to database.",
+		})
+	}
+
+	for i, ok := range inserted {
+		if ok && len(links[i].Tags) > 0 {
+			if err := tagService.SetLinkTags(db.Ctx, tx, links[i].ShortCode, links[i].Tags); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Could not save links to database.",
+				})
+			}
+		}
+	}
+
+	if err := tx.Commit(db.Ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not save links to database.",
+		})
+	}
+	committed = true
+
+	utmTemplate, _ := utmService.GetTemplate(db.Ctx, userID)
+	created := 0
+	for i, ok := range inserted {
+		if !ok {
+			continue
+		}
+		created++
+		cacheLink(links[i].ShortCode, links[i].cacheRecord(utmTemplate))
+		response := links[i].response()
+		results[i].ShortURL = response.ShortURL
+		results[i].ExpiresAt = response.ExpiresAt
+	}
+
+	return c.JSON(fiber.Map{
+		"created": created,
+		"failed":  len(requests) - created,
+		"results": results,
+	})
+}
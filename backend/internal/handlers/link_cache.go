@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
+	"time"
+)
+
+// cachedLink is the link record stored in Redis so the redirect path can
+// answer without querying PostgreSQL
+type cachedLink struct {
+	LongURL   string     `json:"long_url"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	IsActive  bool       `json:"is_active"`
+	MaxClicks int        `json:"max_clicks,omitempty"`
+
+	// PasswordHash is the bcrypt hash for password-protected links
+	PasswordHash string `json:"password_hash,omitempty"`
+
+	// RedirectType is the HTTP status to redirect with (0 = server default)
+	RedirectType int `json:"redirect_type,omitempty"`
+
+	QueryPassthrough *QueryPassthrough `json:"query_passthrough,omitempty"`
+
+	// UTM holds the link's tags merged with its owner's UTM template
+	UTM *services.UTMParams `json:"utm,omitempty"`
+
+	Rules  []RedirectRule `json:"rules,omitempty"`
+	ABTest *ABTest        `json:"ab_test,omitempty"`
+
+	// ActiveFrom is when a scheduled link goes live; before then visitors get
+	// the prelaunch response
+	ActiveFrom       *time.Time `json:"active_from,omitempty"`
+	PrelaunchURL     string     `json:"prelaunch_url,omitempty"`
+	PrelaunchMessage string     `json:"prelaunch_message,omitempty"`
+
+	// Context and CreatedAt are shown on the preview page, which Interstitial
+	// forces before every visit
+	Context      string    `json:"context,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Interstitial bool      `json:"interstitial,omitempty"`
+
+	// ExpiredRedirectURL replaces the expired page once the link is used up
+	ExpiredRedirectURL string `json:"expired_redirect_url,omitempty"`
+
+	AutoRenew bool `json:"auto_renew,omitempty"`
+}
+
+// cacheTTL returns how long the record may stay in Redis. A link that is not
+// live yet is only cached until its activation time, so the pre-launch record
+// is always reloaded once the link goes live.
+func (l *cachedLink) cacheTTL() time.Duration {
+	ttl := linkCacheTTL(l.ExpiresAt)
+	if isPending(l.ActiveFrom) {
+		if untilActive := time.Until(*l.ActiveFrom); untilActive < ttl {
+			ttl = untilActive
+		}
+	}
+	return ttl
+}
+
+// cacheLink stores the link record for a short code until the link expires.
+// Links that have already expired are evicted instead.
+func cacheLink(shortCode string, link *cachedLink) {
+	ttl := link.cacheTTL()
+	if ttl <= 0 {
+		evictLink(shortCode)
+		return
+	}
+	data, err := json.Marshal(link)
+	if err != nil {
+		return
+	}
+	db.RDB.Set(db.Ctx, shortCode, data, ttl).Err()
+}
+
+// evictLink removes the cached link record and QR code for a short code
+func evictLink(shortCode string) {
+	db.RDB.Del(db.Ctx, shortCode, "qr:"+shortCode).Err()
+}
+
+// loadLink returns the link record for a short code, reading from Redis first
+// and falling back to PostgreSQL. Records loaded from PostgreSQL are cached.
+func loadLink(shortCode string) (*cachedLink, error) {
+	link := new(cachedLink)
+
+	// 1. Check Redis (cache) first
+	data, err := db.RDB.Get(db.Ctx, shortCode).Bytes()
+	if err == nil && json.Unmarshal(data, link) == nil {
+		return link, nil
+	}
+
+	// 2. If not in cache, check PostgreSQL
+	selectSQL := `
+		SELECT l.long_url, l.expires_at, l.is_active, COALESCE(l.max_clicks, 0), COALESCE(l.password_hash, ''),
+			COALESCE(l.redirect_type, 0), l.query_passthrough, l.utm, u.utm_template,
+			l.redirect_rules, l.ab_test, l.active_from, COALESCE(l.prelaunch_url, ''), COALESCE(l.prelaunch_message, ''),
+			COALESCE(l.context, ''), l.created_at, l.interstitial, COALESCE(l.expired_redirect_url, ''),
+			l.auto_renew
+		FROM links l
+		LEFT JOIN users u ON u.id = l.user_id
+		WHERE l.short_code = $1
+	`
+	var passthrough, utm, utmTemplate, rules, abTest []byte
+	err = db.DB.QueryRow(db.Ctx, selectSQL, shortCode).Scan(&link.LongURL, &link.ExpiresAt, &link.IsActive, &link.MaxClicks, &link.PasswordHash,
+		&link.RedirectType, &passthrough, &utm, &utmTemplate,
+		&rules, &abTest, &link.ActiveFrom, &link.PrelaunchURL, &link.PrelaunchMessage,
+		&link.Context, &link.CreatedAt, &link.Interstitial, &link.ExpiredRedirectURL,
+		&link.AutoRenew)
+	if err != nil {
+		return nil, err
+	}
+	if len(passthrough) > 0 {
+		json.Unmarshal(passthrough, &link.QueryPassthrough)
+	}
+	link.UTM = services.MergeUTM(decodeUTM(utm), decodeUTM(utmTemplate))
+	if len(rules) > 0 {
+		json.Unmarshal(rules, &link.Rules)
+	}
+	if len(abTest) > 0 {
+		json.Unmarshal(abTest, &link.ABTest)
+	}
+
+	// 3. Cache the result for future requests
+	cacheLink(shortCode, link)
+	return link, nil
+}
+
+// refreshLinkCache reloads a link from PostgreSQL into Redis after it has been
+// changed, so RedirectLink never serves a stale record
+func refreshLinkCache(shortCode string) {
+	evictLink(shortCode)
+	loadLink(shortCode)
+}
+
+// evictUserLinks drops the cached records of every link a user owns, e.g.
+// after their UTM template changes
+func evictUserLinks(userID string) error {
+	rows, err := db.DB.Query(db.Ctx, "SELECT short_code FROM links WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return err
+		}
+		keys = append(keys, shortCode)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return db.RDB.Del(db.Ctx, keys...).Err()
+}
+
+// decodeUTM decodes a UTM JSONB column, returning nil for NULL
+func decodeUTM(data []byte) *services.UTMParams {
+	if len(data) == 0 {
+		return nil
+	}
+	var utm services.UTMParams
+	if err := json.Unmarshal(data, &utm); err != nil {
+		return nil
+	}
+	return &utm
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"gochop/backend/internal/db"
+	"strconv"
+	"time"
+)
+
+// clickCounterKey returns the Redis key holding the redirect count of a click-capped link
+func clickCounterKey(shortCode string) string {
+	return "clicks:" + shortCode
+}
+
+// seedClickCounter makes sure the redirect counter exists, starting it from the
+// clicks already recorded in PostgreSQL. SETNX keeps concurrent seeds from
+// different instances from overwriting each other.
+func seedClickCounter(shortCode string, expiresAt *time.Time) error {
+	key := clickCounterKey(shortCode)
+	if n, err := db.RDB.Exists(db.Ctx, key).Result(); err != nil || n > 0 {
+		return err
+	}
+
+	var clicks int64
+	countSQL := "SELECT COUNT(*) FROM analytics WHERE short_code = $1"
+	if err := db.DB.QueryRow(db.Ctx, countSQL, shortCode).Scan(&clicks); err != nil {
+		return err
+	}
+
+	var ttl time.Duration
+	if expiresAt != nil {
+		ttl = time.Until(*expiresAt)
+	}
+	return db.RDB.SetNX(db.Ctx, key, clicks, ttl).Err()
+}
+
+// consumeClick atomically counts a redirect against a link's click cap.
+// It reports false once the cap has been reached.
+func consumeClick(shortCode string, link *cachedLink) (bool, error) {
+	if link.MaxClicks <= 0 {
+		return true, nil
+	}
+	if err := seedClickCounter(shortCode, link.ExpiresAt); err != nil {
+		return false, err
+	}
+	n, err := db.RDB.Incr(db.Ctx, clickCounterKey(shortCode)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n <= int64(link.MaxClicks), nil
+}
+
+// fillRemainingClicks sets RemainingClicks on the click-capped links. It
+// prefers the live Redis counters, read in a single MGET, and falls back to
+// the recorded click counts.
+func fillRemainingClicks(links []*LinkInfo) {
+	var capped []*LinkInfo
+	var keys []string
+	for _, link := range links {
+		if link.MaxClicks != nil {
+			capped = append(capped, link)
+			keys = append(keys, clickCounterKey(link.key))
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	counters, err := db.RDB.MGet(db.Ctx, keys...).Result()
+
+	for i, link := range capped {
+		used := link.ClickCount
+		if err == nil {
+			if value, ok := counters[i].(string); ok {
+				if n, err := strconv.Atoi(value); err == nil {
+					used = n
+				}
+			}
+		}
+		remaining := 0
+		if used < *link.MaxClicks {
+			remaining = *link.MaxClicks - used
+		}
+		link.RemainingClicks = &remaining
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import "testing"
+
+func TestFillRemainingClicks(t *testing.T) {
+	server := useTestRedis(t)
+	server.Set(clickCounterKey("counted"), "7")
+	server.Set(clickCounterKey("links.example.com/branded"), "10")
+
+	ten := 10
+	counted := &LinkInfo{MaxClicks: &ten, ClickCount: 3, key: "counted"}
+	uncounted := &LinkInfo{MaxClicks: &ten, ClickCount: 4, key: "uncounted"}
+	branded := &LinkInfo{MaxClicks: &ten, ClickCount: 2, key: "links.example.com/branded"}
+	uncapped := &LinkInfo{ClickCount: 50, key: "uncapped"}
+	fillRemainingClicks([]*LinkInfo{counted, uncapped, uncounted, branded})
+
+	tests := []struct {
+		name string
+		link *LinkInfo
+		want int
+	}{
+		{"live counter wins", counted, 3},
+		{"recorded clicks without a counter", uncounted, 6},
+		{"custom domain key, used up", branded, 0},
+	}
+	for _, tt := range tests {
+		if tt.link.RemainingClicks == nil || *tt.link.RemainingClicks != tt.want {
+			t.Errorf("%s: RemainingClicks = %v, want %d", tt.name, tt.link.RemainingClicks, tt.want)
+		}
+	}
+	if uncapped.RemainingClicks != nil {
+		t.Errorf("uncapped link got RemainingClicks = %d", *uncapped.RemainingClicks)
+	}
+}
+
+func TestFillRemainingClicksRedisDown(t *testing.T) {
+	server := useTestRedis(t)
+	server.Close()
+
+	five := 5
+	link := &LinkInfo{MaxClicks: &five, ClickCount: 2, key: "abc"}
+	fillRemainingClicks([]*LinkInfo{link})
+	if link.RemainingClicks == nil || *link.RemainingClicks != 3 {
+		t.Errorf("RemainingClicks = %v, want 3 from the recorded clicks", link.RemainingClicks)
+	}
+}
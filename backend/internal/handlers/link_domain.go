@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var domainService = services.NewDomainService()
+
+// linkKey returns the short_code a link is stored under. Links on the default
+// domain are stored under their code, links on a custom domain under
+// "<hostname>/<code>".
+func linkKey(hostname, code string) string {
+	if hostname == "" {
+		return code
+	}
+	return hostname + "/" + code
+}
+
+// publicCode returns the code visitors type for a link key, without its domain
+func publicCode(key string) string {
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		return key[i+1:]
+	}
+	return key
+}
+
+// getCustomDomainScheme returns the scheme used in branded short URLs
+func getCustomDomainScheme() string {
+	if scheme := os.Getenv("CUSTOM_DOMAIN_SCHEME"); scheme != "" {
+		return scheme
+	}
+	return "https"
+}
+
+// shortURLFor returns the public short URL for a link key, on its custom
+// domain if it has one
+func shortURLFor(key string) string {
+	if hostname, code, ok := strings.Cut(key, "/"); ok {
+		return getCustomDomainScheme() + "://" + hostname + "/" + code
+	}
+	return getBaseURL() + "/" + key
+}
+
+// requestHostname returns the request's Host header without its port
+func requestHostname(c *fiber.Ctx) string {
+	host := c.Hostname()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return services.NormalizeDomain(host)
+}
+
+// resolveLinkKey returns the key of the link addressed by the :shortCode
+// parameter on a public route. The domain comes from the Host header when the
+// request arrives on a verified custom domain, so custom-domain links are only
+// reachable on their own domain and a "domain" query parameter is left for
+// query passthrough.
+func resolveLinkKey(c *fiber.Ctx) string {
+	code := c.Params("shortCode")
+	if host := requestHostname(c); domainService.IsCustomHost(db.Ctx, host) {
+		return linkKey(host, code)
+	}
+	return code
+}
+
+// resolveAPILinkKey returns the key of the link addressed by the :shortCode
+// parameter on an API route, where the link's custom domain is given with the
+// ?domain= query parameter
+func resolveAPILinkKey(c *fiber.Ctx) string {
+	code := c.Params("shortCode")
+	if domain := services.NormalizeDomain(c.Query("domain")); domain != "" {
+		return linkKey(domain, code)
+	}
+	return code
+}
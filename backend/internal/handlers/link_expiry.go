@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// neverExpires is the expires_in value for links that should not expire
+const neverExpires = "never"
+
+// parseDuration parses a Go duration string, additionally accepting a whole
+// number of days such as "30d"
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// getDurationEnv reads a duration from the environment, returning the default
+// when the variable is unset or invalid
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := parseDuration(value)
+	if err != nil {
+		return defaultValue
+	}
+	return d
+}
+
+// getMinExpiration returns the shortest lifetime a link may be given
+func getMinExpiration() time.Duration {
+	return getDurationEnv("MIN_LINK_EXPIRATION", time.Minute)
+}
+
+// getMaxExpiration returns the longest lifetime a link may be given, or 0 for no limit
+func getMaxExpiration() time.Duration {
+	return getDurationEnv("MAX_LINK_EXPIRATION", 0)
+}
+
+// allowNeverExpire reports whether links may be created without an expiry
+func allowNeverExpire() bool {
+	return os.Getenv("ALLOW_NEVER_EXPIRING_LINKS") != "false"
+}
+
+// resolveExpiration turns the expires_at / expires_in request fields into an
+// expiry time, validated against the configured limits. A nil result means the
+// link never expires. When neither field is set, defaultExpiration applies.
+func resolveExpiration(expiresAt *time.Time, expiresIn string) (*time.Time, error) {
+	now := time.Now()
+	expiresIn = strings.TrimSpace(expiresIn)
+
+	if expiresAt != nil && expiresIn != "" {
+		return nil, fmt.Errorf("only one of expires_at and expires_in may be set")
+	}
+
+	if strings.EqualFold(expiresIn, neverExpires) {
+		if !allowNeverExpire() {
+			return nil, fmt.Errorf("links without an expiration are not allowed")
+		}
+		return nil, nil
+	}
+
+	var lifetime time.Duration
+	switch {
+	case expiresAt != nil:
+		lifetime = expiresAt.Sub(now)
+	case expiresIn != "":
+		d, err := parseDuration(expiresIn)
+		if err != nil {
+			return nil, fmt.Errorf("expires_in must be a duration such as \"72h\" or \"30d\", or \"never\"")
+		}
+		lifetime = d
+	default:
+		lifetime = defaultExpiration
+		if maxLifetime := getMaxExpiration(); maxLifetime > 0 && lifetime > maxLifetime {
+			lifetime = maxLifetime
+		}
+	}
+
+	if lifetime < getMinExpiration() {
+		return nil, fmt.Errorf("expiration must be at least %s in the future", getMinExpiration())
+	}
+	if maxLifetime := getMaxExpiration(); maxLifetime > 0 && lifetime > maxLifetime {
+		return nil, fmt.Errorf("expiration cannot be more than %s in the future", maxLifetime)
+	}
+
+	expires := now.Add(lifetime)
+	if expiresAt != nil {
+		expires = *expiresAt
+	}
+	return &expires, nil
+}
+
+// isExpired reports whether a link with the given expiry has expired
+func isExpired(expiresAt *time.Time) bool {
+	return expiresAt != nil && time.Now().After(*expiresAt)
+}
+
+// linkCacheTTL returns how long a link record may stay in Redis. Links that
+// never expire are cached for cacheDuration rather than with a zero TTL, which
+// Redis would treat as "keep forever".
+func linkCacheTTL(expiresAt *time.Time) time.Duration {
+	if expiresAt == nil {
+		return cacheDuration
+	}
+	return time.Until(*expiresAt)
+}
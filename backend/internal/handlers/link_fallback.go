@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"gochop/backend/internal/db"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FallbackURLRequest defines the body for setting the user's fallback URL
+type FallbackURLRequest struct {
+	FallbackURL string `json:"fallback_url"`
+}
+
+// ownerFallbackURL returns where visitors of an expired link go when the link
+// has no expired_redirect_url: its custom domain's fallback URL, or else its
+// owner's. It is empty when neither is set.
+func ownerFallbackURL(shortCode string) string {
+	var fallbackURL string
+	db.DB.QueryRow(db.Ctx, `
+		SELECT COALESCE(d.fallback_url, u.fallback_url, '')
+		FROM links l
+		LEFT JOIN domains d ON d.id = l.domain_id
+		LEFT JOIN users u ON u.id = l.user_id
+		WHERE l.short_code = $1
+	`, shortCode).Scan(&fallbackURL)
+	return fallbackURL
+}
+
+// serveExpired sends visitors of an expired or used-up link to its fallback
+// destination, or shows the expired page when there is none. Neither is a
+// click, so they do not count towards analytics or the click cap.
+func serveExpired(c *fiber.Ctx, shortCode string, link *cachedLink, message string) error {
+	c.Locals("skipAnalytics", true)
+	fallbackURL := link.ExpiredRedirectURL
+	if fallbackURL == "" {
+		fallbackURL = ownerFallbackURL(shortCode)
+	}
+	if fallbackURL == "" {
+		return renderErrorPage(c, fiber.StatusGone, pageExpired, message)
+	}
+	c.Set("Cache-Control", "no-store")
+	return c.Redirect(fallbackURL, fiber.StatusFound)
+}
+
+// serveNotFound answers requests for links that do not exist. On a custom
+// domain with a fallback URL visitors are sent there instead.
+func serveNotFound(c *fiber.Ctx, shortCode string) error {
+	c.Locals("skipAnalytics", true)
+	if hostname, _, ok := strings.Cut(shortCode, "/"); ok {
+		var fallbackURL string
+		db.DB.QueryRow(db.Ctx, "SELECT COALESCE(fallback_url, '') FROM domains WHERE hostname = $1 AND verified_at IS NOT NULL", hostname).Scan(&fallbackURL)
+		if fallbackURL != "" {
+			c.Set("Cache-Control", "no-store")
+			return c.Redirect(fallbackURL, fiber.StatusFound)
+		}
+	}
+	return renderErrorPage(c, fiber.StatusNotFound, pageNotFound, "Short link not found")
+}
+
+// GetFallbackURL returns where the current user's expired links redirect to
+func GetFallbackURL(c *fiber.Ctx) error {
+	// Get user ID from context (set by NextAuth middleware)
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	fallbackURL, err := userService.GetFallbackURL(db.Ctx, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve fallback URL",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"fallback_url": fallbackURL,
+	})
+}
+
+// UpdateFallbackURL sets where the current user's expired links redirect to
+// when they have no expired_redirect_url of their own; an empty URL removes it
+func UpdateFallbackURL(c *fiber.Ctx) error {
+	// Get user ID from context (set by NextAuth middleware)
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	req := new(FallbackURLRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.FallbackURL != "" {
+		if err := validateURL(req.FallbackURL); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	if err := userService.SetFallbackURL(db.Ctx, userID, req.FallbackURL); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update fallback URL",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "Fallback URL updated successfully",
+		"fallback_url": req.FallbackURL,
+	})
+}
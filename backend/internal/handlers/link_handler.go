@@ -1,22 +1,25 @@
 package handlers
 
 import (
-	"crypto/rand"
+	"context"
+	"errors"
 	"fmt"
 	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
+	"log"
 	"net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
 	"github.com/skip2/go-qrcode"
 )
 
 const (
-	letterBytes       = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	shortCodeLength   = 6
 	cacheDuration     = 6 * time.Hour
 	defaultExpiration = 90 * 24 * time.Hour // 90 days
 )
@@ -30,7 +33,24 @@ func getBaseURL() string {
 	return baseURL
 }
 
-// validateURL checks if the provided URL is valid
+// getDefaultRedirectType returns the redirect status used by links that do not set their own
+func getDefaultRedirectType() int {
+	if redirectType, err := strconv.Atoi(os.Getenv("DEFAULT_REDIRECT_TYPE")); err == nil && validateRedirectType(redirectType) == nil && redirectType != 0 {
+		return redirectType
+	}
+	return fiber.StatusFound // 302 keeps browsers coming back, so clicks stay counted
+}
+
+// redirectStatus returns the HTTP status for a link's redirect type
+func redirectStatus(redirectType int) int {
+	if redirectType == 0 {
+		return getDefaultRedirectType()
+	}
+	return redirectType
+}
+
+// validateURL checks if the provided URL is a valid destination: an http(s)
+// URL that passes checkURLPolicy, or a mailto:/tel: link where allowed
 func validateURL(urlStr string) error {
 	if urlStr == "" {
 		return fmt.Errorf("URL cannot be empty")
@@ -44,12 +64,39 @@ func validateURL(urlStr string) error {
 	if parsedURL.Scheme == "" {
 		return fmt.Errorf("URL must include a scheme (http:// or https://)")
 	}
+
+	switch strings.ToLower(parsedURL.Scheme) {
+	case "http", "https":
+	case "mailto", "tel":
+		if !allowContactSchemes() {
+			return fmt.Errorf("mailto: and tel: links are not allowed")
+		}
+		if parsedURL.Opaque == "" {
+			return fmt.Errorf("URL must include an address or number")
+		}
+		return nil
+	default:
+		return fmt.Errorf("URL scheme must be http or https")
+	}
 	
 	if parsedURL.Host == "" {
 		return fmt.Errorf("URL must include a host")
 	}
 	
-	return nil
+	return checkURLPolicy(parsedURL)
+}
+
+// reservedAliases cannot be used as custom aliases
+var reservedAliases = []string{"api", "admin", "www", "app", "help", "support", "about"}
+
+// isReservedAlias reports whether the alias is a reserved word
+func isReservedAlias(alias string) bool {
+	for _, word := range reservedAliases {
+		if strings.ToLower(alias) == word {
+			return true
+		}
+	}
+	return false
 }
 
 // validateAlias checks if the provided alias is valid
@@ -70,11 +117,8 @@ func validateAlias(alias string) error {
 	}
 	
 	// Prevent reserved words
-	reserved := []string{"api", "admin", "www", "app", "help", "support", "about"}
-	for _, word := range reserved {
-		if strings.ToLower(alias) == word {
-			return fmt.Errorf("alias '%s' is reserved", alias)
-		}
+	if isReservedAlias(alias) {
+		return fmt.Errorf("alias '%s' is reserved", alias)
 	}
 	
 	return nil
@@ -88,34 +132,95 @@ func validateContext(context string) error {
 	return nil
 }
 
+// validateMaxClicks checks if the provided click cap is valid
+func validateMaxClicks(maxClicks int) error {
+	if maxClicks < 0 {
+		return fmt.Errorf("max_clicks cannot be negative")
+	}
+	return nil
+}
+
+// validateRedirectType checks if the provided redirect type is valid (0 means server default)
+func validateRedirectType(redirectType int) error {
+	switch redirectType {
+	case 0, fiber.StatusMovedPermanently, fiber.StatusFound, fiber.StatusTemporaryRedirect, fiber.StatusPermanentRedirect:
+		return nil
+	}
+	return fmt.Errorf("redirect_type must be one of 301, 302, 307 or 308")
+}
+
 // ShortenRequest defines the structure for the /api/shorten request body.
+// Expiry is given either as an absolute expires_at or a relative expires_in
+// ("72h", "30d" or "never"); without either the link expires after 90 days.
 type ShortenRequest struct {
-	LongURL string `json:"long_url"`
-	Alias   string `json:"alias,omitempty"`
-	Context string `json:"context,omitempty"`
+	LongURL   string     `json:"long_url"`
+	Alias     string     `json:"alias,omitempty"`
+	Context   string     `json:"context,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn string     `json:"expires_in,omitempty"`
+	MaxClicks int        `json:"max_clicks,omitempty"`
+	Password  string     `json:"password,omitempty"`
+
+	// RedirectType is 301, 302, 307 or 308; omitted uses the server default
+	RedirectType int `json:"redirect_type,omitempty"`
+
+	// QueryPassthrough forwards the short URL's query parameters to the destination
+	QueryPassthrough *QueryPassthrough `json:"query_passthrough,omitempty"`
+
+	// UTM tags are appended to the destination at redirect time; unset tags
+	// fall back to the user's UTM template
+	UTM *services.UTMParams `json:"utm,omitempty"`
+
+	// Rules route matching visitors to other destinations, first match wins
+	Rules []RedirectRule `json:"rules,omitempty"`
+
+	// ABTest splits visitors no rule matched between weighted destinations
+	ABTest *ABTest `json:"ab_test,omitempty"`
+
+	// ActiveFrom schedules the link to go live later. Until then visitors are
+	// sent to PrelaunchURL if set, or shown PrelaunchMessage.
+	ActiveFrom       *time.Time `json:"active_from,omitempty"`
+	PrelaunchURL     string     `json:"prelaunch_url,omitempty"`
+	PrelaunchMessage string     `json:"prelaunch_message,omitempty"`
+
+	// Interstitial shows the destination preview page before every visit
+	Interstitial bool `json:"interstitial,omitempty"`
+
+	// Tags label the link; tags the user does not have yet are created
+	Tags []string `json:"tags,omitempty"`
+
+	// Domain is a verified custom domain of the user to create the link on
+	Domain string `json:"domain,omitempty"`
+
+	// ExpiredRedirectURL is where visitors go once the link has expired or
+	// reached its click limit, instead of the expired page
+	ExpiredRedirectURL string `json:"expired_redirect_url,omitempty"`
+
+	// AutoRenew pushes the expiry back when the link is clicked shortly
+	// before it expires
+	AutoRenew bool `json:"auto_renew,omitempty"`
 }
 
 // ShortenResponse defines the structure for the /api/shorten response.
+// ExpiresAt is null for links that never expire.
 type ShortenResponse struct {
-	ShortURL  string    `json:"short_url"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ShortURL  string     `json:"short_url"`
+	ExpiresAt *time.Time `json:"expires_at"`
 }
 
-// generateShortCode creates a cryptographically secure random string of a fixed length.
-func generateShortCode() string {
-	b := make([]byte, shortCodeLength)
-	for i := range b {
-		// Generate a random byte
-		randomBytes := make([]byte, 1)
-		_, err := rand.Read(randomBytes)
-		if err != nil {
-			// Fallback to time-based generation if crypto/rand fails
-			b[i] = letterBytes[int(time.Now().UnixNano())%len(letterBytes)]
-		} else {
-			b[i] = letterBytes[int(randomBytes[0])%len(letterBytes)]
-		}
-	}
-	return string(b)
+// shortCodeService generates codes for links without a custom alias
+var shortCodeService = services.NewShortCodeService()
+
+// codePool hands out codes generated in advance for the default domain
+var codePool = services.NewCodePool(shortCodeService)
+
+// maxCodeClaimAttempts caps how often a generated code is replaced when it
+// turns out to be taken at insert time
+const maxCodeClaimAttempts = 3
+
+// RunCodePool keeps the short code pool topped up until the context is cancelled
+func RunCodePool(ctx context.Context) {
+	codePool.Run(ctx)
 }
 
 func isShortCodeTaken(shortCode string) (bool, error) {
@@ -125,69 +230,328 @@ func isShortCodeTaken(shortCode string) (bool, error) {
 	return exists, err
 }
 
-// generateUniqueShortCode creates a random, unique short code.
-func generateUniqueShortCode() (string, error) {
-	for i := 0; i < 5; i++ { // Retry up to 5 times
-		code := generateShortCode()
-		taken, err := isShortCodeTaken(code)
+// generateUniqueShortCode creates a short code for the destination that is
+// unique on the given domain ("" for the default domain).
+func generateUniqueShortCode(hostname, longURL string) (string, error) {
+	return shortCodeService.Generate(db.Ctx, longURL, func(code string) (bool, error) {
+		return isShortCodeTaken(linkKey(hostname, code))
+	})
+}
+
+// allocateShortCode returns the link key for a new link without a custom
+// alias. Links on the default domain take a code from the pool; when it is
+// empty, and for custom domains, a code is generated on the spot.
+func allocateShortCode(hostname, longURL string) (string, error) {
+	if hostname == "" {
+		if code, err := codePool.Take(db.Ctx); err == nil {
+			return code, nil
+		}
+	}
+	code, err := generateUniqueShortCode(hostname, longURL)
+	if err != nil {
+		return "", err
+	}
+	return linkKey(hostname, code), nil
+}
+
+// releaseShortCodes drops the pool reservations of generated codes whose
+// link was not saved, so they are not held forever. Codes that were not
+// reserved are ignored.
+func releaseShortCodes(codes []string) {
+	if len(codes) == 0 {
+		return
+	}
+	if _, err := db.DB.Exec(db.Ctx, releaseReservationsSQL, codes); err != nil {
+		log.Printf("Could not release short codes: %v", err)
+	}
+}
+
+// newLink is a validated link ready to be inserted. ShortCode is the link
+// key, which includes the custom domain if there is one. Alias is set when
+// the code was chosen by the user rather than generated.
+type newLink struct {
+	ShortCode    string
+	Alias        string
+	Hostname     string
+	LongURL      string
+	Context      string
+	ExpiresAt    *time.Time
+	MaxClicks    int
+	PasswordHash string
+	RedirectType int
+
+	QueryPassthrough *QueryPassthrough
+	UTM              *services.UTMParams
+	Rules            []RedirectRule
+	ABTest           *ABTest
+
+	ActiveFrom       *time.Time
+	PrelaunchURL     string
+	PrelaunchMessage string
+
+	CreatedAt    time.Time
+	Interstitial bool
+	Tags         []string
+	DomainID     int
+
+	ExpiredRedirectURL string
+	AutoRenew          bool
+
+	// lost holds the generated codes that a concurrent insert claimed first
+	lost []string
+}
+
+// insertLinkSQL inserts a newLink unless its short code is taken, claiming
+// the code in the same statement; use with newLink.insertArgs
+const insertLinkSQL = `
+	INSERT INTO links (short_code, long_url, context, expires_at, user_id, max_clicks, password_hash, redirect_type,
+		query_passthrough, utm, redirect_rules, ab_test, active_from, prelaunch_url, prelaunch_message,
+		created_at, interstitial, domain_id, expired_redirect_url, auto_renew)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, ''), NULLIF($8, 0), $9, $10, $11, $12,
+		$13, NULLIF($14, ''), NULLIF($15, ''), $16, $17, NULLIF($18, 0), NULLIF($19, ''), $20)
+	ON CONFLICT (short_code) DO NOTHING
+`
+
+// releaseReservationsSQL drops the pool reservations of codes now used by links
+const releaseReservationsSQL = "DELETE FROM reserved_codes WHERE code = ANY($1)"
+
+// insertArgs returns the arguments for insertLinkSQL
+func (l *newLink) insertArgs(userID string) []interface{} {
+	return []interface{}{l.ShortCode, l.LongURL, l.Context, l.ExpiresAt, userID, l.MaxClicks, l.PasswordHash, l.RedirectType,
+		jsonOrNull(l.QueryPassthrough), jsonOrNull(l.UTM), jsonOrNull(l.Rules), jsonOrNull(l.ABTest),
+		l.ActiveFrom, l.PrelaunchURL, l.PrelaunchMessage, l.CreatedAt, l.Interstitial, l.DomainID,
+		l.ExpiredRedirectURL, l.AutoRenew}
+}
+
+// insert inserts the link in tx. A generated code that was claimed in the
+// meantime is replaced by a new one; a taken custom alias yields
+// *aliasTakenError with available alternatives.
+func (l *newLink) insert(tx pgx.Tx, userID string) error {
+	// Pool codes that lost to a concurrent insert are in use by that link now,
+	// so their reservations are dropped along with the winning code's
+	for attempt := 0; ; attempt++ {
+		tag, err := tx.Exec(db.Ctx, insertLinkSQL, l.insertArgs(userID)...)
 		if err != nil {
-			return "", err
+			return err
 		}
-		if !taken {
-			return code, nil
+		if tag.RowsAffected() == 1 {
+			break
+		}
+		if l.Alias != "" {
+			suggestions, err := suggestAliases(l.Hostname, l.Alias)
+			if err != nil {
+				return err
+			}
+			return &aliasTakenError{Suggestions: suggestions}
 		}
+		if l.DomainID == 0 {
+			l.lost = append(l.lost, l.ShortCode)
+		}
+		if attempt+1 == maxCodeClaimAttempts {
+			if len(l.lost) > 0 {
+				if _, err := tx.Exec(db.Ctx, releaseReservationsSQL, l.lost); err != nil {
+					return err
+				}
+			}
+			return services.ErrShortCodeExhausted
+		}
+		if l.ShortCode, err = allocateShortCode(l.Hostname, l.LongURL); err != nil {
+			return err
+		}
+	}
+
+	if l.DomainID == 0 {
+		_, err := tx.Exec(db.Ctx, releaseReservationsSQL, append(l.lost, l.ShortCode))
+		return err
 	}
-	return "", fmt.Errorf("could not generate a unique short code")
+	return nil
 }
 
-// ShortenLink handles the creation of a new shortened link.
-func ShortenLink(c *fiber.Ctx) error {
-	req := new(ShortenRequest)
-	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Cannot parse JSON",
-		})
+// generatedCodes returns the default-domain codes allocated for the link,
+// whose reservations must be released if it is not saved
+func (l *newLink) generatedCodes() []string {
+	if l.Alias != "" || l.DomainID != 0 {
+		return nil
+	}
+	return append(append([]string{}, l.lost...), l.ShortCode)
+}
+
+// cacheRecord returns the Redis record for a freshly created link, given its
+// owner's UTM template
+func (l *newLink) cacheRecord(utmTemplate *services.UTMParams) *cachedLink {
+	return &cachedLink{
+		LongURL:      l.LongURL,
+		ExpiresAt:    l.ExpiresAt,
+		IsActive:     true,
+		MaxClicks:    l.MaxClicks,
+		PasswordHash: l.PasswordHash,
+		RedirectType: l.RedirectType,
+
+		QueryPassthrough: l.QueryPassthrough,
+		UTM:              services.MergeUTM(l.UTM, utmTemplate),
+		Rules:            l.Rules,
+		ABTest:           l.ABTest,
+
+		ActiveFrom:       l.ActiveFrom,
+		PrelaunchURL:     l.PrelaunchURL,
+		PrelaunchMessage: l.PrelaunchMessage,
+
+		Context:      l.Context,
+		CreatedAt:    l.CreatedAt,
+		Interstitial: l.Interstitial,
+
+		ExpiredRedirectURL: l.ExpiredRedirectURL,
+		AutoRenew:          l.AutoRenew,
 	}
+}
+
+// response returns the API response for a freshly created link
+func (l *newLink) response() ShortenResponse {
+	return ShortenResponse{
+		ShortURL:  shortURLFor(l.ShortCode),
+		ExpiresAt: l.ExpiresAt,
+	}
+}
 
+// prepareLink validates a shorten request by the given user and resolves its
+// domain, short code, expiry and password hash. Errors are *fiber.Error
+// values carrying the HTTP status. Custom aliases are not checked here but
+// claimed when the link is inserted.
+func prepareLink(req *ShortenRequest, userID string) (*newLink, error) {
 	// Validate input
 	if err := validateURL(req.LongURL); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
 	if err := validateAlias(req.Alias); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
 	if err := validateContext(req.Context); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	var shortCode string
-	var err error
+	if err := validateMaxClicks(req.MaxClicks); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
 
-	if req.Alias != "" {
-		shortCode = strings.TrimSpace(req.Alias)
-		taken, err := isShortCodeTaken(shortCode)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	if err := validatePassword(req.Password); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := validateRedirectType(req.RedirectType); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := validateQueryPassthrough(req.QueryPassthrough); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := req.UTM.Validate(); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if req.UTM.IsEmpty() {
+		req.UTM = nil
+	}
+
+	if err := validateRedirectRules(req.Rules); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := validateABTest(req.ABTest); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if req.ExpiredRedirectURL != "" {
+		if err := validateURL(req.ExpiredRedirectURL); err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "expired_redirect_url: "+err.Error())
 		}
-		if taken {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Custom alias is already taken."})
+	}
+
+	tags, err := services.NormalizeTagNames(req.Tags)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	expiresAt, err := resolveExpiration(req.ExpiresAt, req.ExpiresIn)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := validateActivation(req.ActiveFrom, expiresAt, req.PrelaunchURL, req.PrelaunchMessage); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	var hostname string
+	var domainID int
+	if req.Domain != "" {
+		hostname = services.NormalizeDomain(req.Domain)
+		domainID, err = domainService.GetVerifiedDomainID(db.Ctx, userID, hostname)
+		if errors.Is(err, services.ErrDomainNotFound) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Domain is not one of your verified domains.")
+		}
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "Database error")
 		}
+	}
+
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Could not hash password.")
+	}
+
+	// The code is allocated last, since nothing after this may fail and leave
+	// a pool code reserved but unused
+	var shortCode string
+	alias := strings.TrimSpace(req.Alias)
+
+	if alias != "" {
+		shortCode = linkKey(hostname, alias)
 	} else {
-		shortCode, err = generateUniqueShortCode()
+		shortCode, err = allocateShortCode(hostname, req.LongURL)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			return nil, fiber.NewError(fiber.StatusInternalServerError, err.Error())
 		}
 	}
 
-	expiresAt := time.Now().Add(defaultExpiration)
+	return &newLink{
+		ShortCode:    shortCode,
+		Alias:        alias,
+		Hostname:     hostname,
+		LongURL:      req.LongURL,
+		Context:      req.Context,
+		ExpiresAt:    expiresAt,
+		MaxClicks:    req.MaxClicks,
+		PasswordHash: passwordHash,
+		RedirectType: req.RedirectType,
+
+		QueryPassthrough: req.QueryPassthrough,
+		UTM:              req.UTM,
+		Rules:            req.Rules,
+		ABTest:           req.ABTest,
+
+		ActiveFrom:       req.ActiveFrom,
+		PrelaunchURL:     req.PrelaunchURL,
+		PrelaunchMessage: req.PrelaunchMessage,
+
+		CreatedAt:    time.Now(),
+		Interstitial: req.Interstitial,
+		Tags:         tags,
+		DomainID:     domainID,
+
+		ExpiredRedirectURL: req.ExpiredRedirectURL,
+		AutoRenew:          req.AutoRenew,
+	}, nil
+}
+
+// ShortenLink handles the creation of a new shortened link.
+func ShortenLink(c *fiber.Ctx) error {
+	req := new(ShortenRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
 
 	// Get user ID from context (authentication is required for this endpoint)
 	userID, ok := c.Locals("userID").(string)
@@ -197,11 +561,42 @@ func ShortenLink(c *fiber.Ctx) error {
 		})
 	}
 
-	// Insert into PostgreSQL with user_id (always authenticated)
-	insertSQL := `INSERT INTO links (short_code, long_url, context, expires_at, user_id) VALUES ($1, $2, $3, $4, $5)`
-	args := []interface{}{shortCode, req.LongURL, req.Context, expiresAt, userID}
-	
-	_, err = db.DB.Exec(db.Ctx, insertSQL, args...)
+	link, err := prepareLink(req, userID)
+	if err != nil {
+		return err
+	}
+
+	// Insert into PostgreSQL with user_id (always authenticated), together
+	// with the link's tags
+	tx, err := db.DB.Begin(db.Ctx)
+	if err != nil {
+		releaseShortCodes(link.generatedCodes())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	defer tx.Rollback(db.Ctx)
+
+	err = link.insert(tx, userID)
+	if err == nil {
+		err = recordRevisions(tx, []string{link.ShortCode}, userID, nil)
+	}
+	if err == nil && len(link.Tags) > 0 {
+		err = tagService.SetLinkTags(db.Ctx, tx, link.ShortCode, link.Tags)
+	}
+	if err == nil {
+		err = tx.Commit(db.Ctx)
+	}
+	if err != nil {
+		// Roll back first: the transaction may hold locks on the reservations
+		tx.Rollback(db.Ctx)
+		releaseShortCodes(link.generatedCodes())
+	}
+	var aliasTaken *aliasTakenError
+	if errors.As(err, &aliasTaken) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":       aliasTaken.Error(),
+			"suggestions": aliasTaken.Suggestions,
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not save link to database.",
@@ -209,23 +604,17 @@ func ShortenLink(c *fiber.Ctx) error {
 	}
 
 	// Set in Redis cache
-	err = db.RDB.Set(db.Ctx, shortCode, req.LongURL, time.Until(expiresAt)).Err()
-	if err != nil {
-		// Log and ignore cache error
-	}
-
-	shortURL := getBaseURL() + "/" + shortCode
+	utmTemplate, _ := utmService.GetTemplate(db.Ctx, userID)
+	cacheLink(link.ShortCode, link.cacheRecord(utmTemplate))
 
-	return c.JSON(ShortenResponse{
-		ShortURL:  shortURL,
-		ExpiresAt: expiresAt,
-	})
+	return c.JSON(link.response())
 }
 
-// GenerateQRCode serves a QR code image for a given short link.
+// GenerateQRCode serves a QR code image for a given short link. Links on a
+// custom domain are addressed with ?domain= and encode their branded URL.
 func GenerateQRCode(c *fiber.Ctx) error {
-	shortCode := c.Params("shortCode")
-	shortURL := getBaseURL() + "/" + shortCode
+	shortCode := resolveAPILinkKey(c)
+	shortURL := shortURLFor(shortCode)
 	redisKey := "qr:" + shortCode
 
 	// 1. Check if QR code is cached in Redis
@@ -244,41 +633,113 @@ func GenerateQRCode(c *fiber.Ctx) error {
 	}
 
 	// 3. Cache the new QR code PNG in Redis
-	// We use the original link's expiration duration for the QR code's cache.
-	linkExpiresIn, _ := db.RDB.TTL(db.Ctx, shortCode).Result()
-	if linkExpiresIn > 0 {
-		db.RDB.Set(db.Ctx, redisKey, png, linkExpiresIn).Err()
+	// We use the original link's cache lifetime for the QR code's cache, so
+	// links that never expire do not leave QR codes in Redis forever.
+	if link, err := loadLink(shortCode); err == nil {
+		if ttl := linkCacheTTL(link.ExpiresAt); ttl > 0 {
+			db.RDB.Set(db.Ctx, redisKey, png, ttl).Err()
+		}
 	}
 
 	c.Set("Content-Type", "image/png")
 	return c.Send(png)
 }
 
-// RedirectLink handles redirecting a short link to its original URL.
+// RedirectLink handles redirecting a short link to its original URL. The link
+// is looked up by the request's host and short code.
 func RedirectLink(c *fiber.Ctx) error {
-	shortCode := c.Params("shortCode")
+	shortCode := resolveLinkKey(c)
+	c.Locals("linkKey", shortCode)
 
-	// 1. Check Redis (cache) first
-	longURL, err := db.RDB.Get(db.Ctx, shortCode).Result()
-	if err == nil {
-		return c.Redirect(longURL, fiber.StatusMovedPermanently)
+	// Look up the link (Redis first, PostgreSQL as a fallback)
+	link, err := loadLink(shortCode)
+	if err != nil {
+		return serveNotFound(c, shortCode)
 	}
 
-	// 2. If not in cache, check PostgreSQL
-	var expiresAt time.Time
-	selectSQL := `SELECT long_url, expires_at FROM links WHERE short_code = $1`
-	err = db.DB.QueryRow(db.Ctx, selectSQL, shortCode).Scan(&longURL, &expiresAt)
+	return serveRedirect(c, shortCode, link)
+}
+
+// serveUnavailable answers requests for links that cannot be followed right
+// now: deactivated, expired or not yet live. None of these are clicks. It
+// reports false, without responding, when the link is available.
+func serveUnavailable(c *fiber.Ctx, shortCode string, link *cachedLink) (bool, error) {
+	// Check if the link has been deactivated by its owner
+	if !link.IsActive {
+		c.Locals("skipAnalytics", true)
+		return true, renderErrorPage(c, fiber.StatusGone, pageDisabled, "This link has been deactivated.")
+	}
+
+	// Expired links send visitors to their fallback destination, if any
+	if isExpired(link.ExpiresAt) {
+		return true, serveExpired(c, shortCode, link, "This link has expired.")
+	}
+
+	// Scheduled links answer with their prelaunch response until they go live
+	if isPending(link.ActiveFrom) {
+		return true, servePrelaunch(c, link)
+	}
+	return false, nil
+}
+
+// serveRedirect applies the link's access rules and redirects to its destination
+func serveRedirect(c *fiber.Ctx, shortCode string, link *cachedLink) error {
+	// 1. Deactivated, expired and scheduled links are not followed
+	if handled, err := serveUnavailable(c, shortCode, link); handled {
+		return err
+	}
+
+	// 2. Password-protected links prompt for the password unless recently unlocked
+	unlocked, _ := c.Locals("linkUnlocked").(bool)
+	if link.PasswordHash != "" && !unlocked && !hasValidUnlockCookie(c, shortCode, link) {
+		return renderUnlockPage(c, fiber.StatusOK, shortCode, "")
+	}
+
+	// 3. Links with a forced interstitial show the preview page first, unless the
+	// visitor just came from it or has just entered the password
+	if link.Interstitial && !unlocked && !passedInterstitial(c, shortCode) {
+		return renderPreviewPage(c, shortCode, link)
+	}
+
+	// 4. Enforce the click cap, counted atomically in Redis across instances
+	allowed, err := consumeClick(shortCode, link)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).SendString("Short link not found")
+		c.Locals("skipAnalytics", true)
+		return c.Status(fiber.StatusServiceUnavailable).SendString("Could not verify the link's click limit.")
+	}
+	if !allowed {
+		return serveExpired(c, shortCode, link, "This link has reached its click limit.")
 	}
 
-	// 3. Check if the link has expired
-	if time.Now().After(expiresAt) {
-		return c.Status(fiber.StatusGone).SendString("This link has expired.")
+	// Links set to auto-renew stay alive while they keep getting clicks
+	if link.AutoRenew && link.ExpiresAt != nil && time.Until(*link.ExpiresAt) < services.ExpiringSoonWindow {
+		go autoRenewLink(shortCode)
 	}
 
-	// 4. Cache the result for future requests
-	db.RDB.Set(db.Ctx, shortCode, longURL, time.Until(expiresAt)).Err()
+	// 5. Pick the destination from the link's redirect rules or A/B test, append
+	// its UTM tags, then forward the short URL's query parameters if the link asks for it
+	destination, variant := resolveDestination(c, shortCode, link)
+	if variant != "" {
+		c.Locals("variant", variant)
+	}
+	destination = applyUTM(destination, link.UTM)
+	if incoming, err := url.ParseQuery(string(c.Request().URI().QueryString())); err == nil {
+		var forwarded url.Values
+		destination, forwarded = mergeQuery(destination, incoming, link.QueryPassthrough)
+		if len(forwarded) > 0 {
+			c.Locals("forwardedParams", forwarded.Encode())
+		}
+	}
+
+	if campaign := utmCampaign(destination); campaign != "" {
+		c.Locals("utmCampaign", campaign)
+	}
 
-	return c.Redirect(longURL, fiber.StatusMovedPermanently)
-} 
\ No newline at end of file
+	// The unlock form arrives as a POST, which 307 and 308 would make the
+	// browser re-send, password included, to the destination
+	status := redirectStatus(link.RedirectType)
+	if c.Method() == fiber.MethodPost {
+		status = fiber.StatusSeeOther
+	}
+	return c.Redirect(destination, status)
+}
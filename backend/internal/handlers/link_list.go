@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gochop/backend/internal/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultLinkPageSize = 50
+	maxLinkPageSize     = 200
+)
+
+// linkSortColumns maps the sort options of the link list to SQL expressions.
+// Links that never expire sort as if they expired at infinity.
+var linkSortColumns = map[string]string{
+	"created_at": "l.created_at",
+	"expires_at": "COALESCE(l.expires_at, 'infinity'::timestamptz)",
+	"clicks":     "(SELECT COUNT(*) FROM analytics a WHERE a.short_code = l.short_code)",
+}
+
+// linkCursor marks the last link of a page: its sort value and ID
+type linkCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// encode returns the opaque cursor string handed to clients
+func (cur *linkCursor) encode() string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeLinkCursor parses a cursor string created for the given sort
+func decodeLinkCursor(value, sort string) (*linkCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cur linkCursor
+	if err := json.Unmarshal(data, &cur); err != nil || cur.Sort != sort {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cur, nil
+}
+
+// cursorAfter returns the cursor pointing past the given link
+func cursorAfter(link *LinkInfo, sort string) *linkCursor {
+	cur := &linkCursor{Sort: sort, ID: link.ID}
+	switch sort {
+	case "created_at":
+		cur.Value = link.CreatedAt.Format(time.RFC3339Nano)
+	case "expires_at":
+		cur.Value = "infinity"
+		if link.ExpiresAt != nil {
+			cur.Value = link.ExpiresAt.Format(time.RFC3339Nano)
+		}
+	case "clicks":
+		cur.Value = strconv.Itoa(link.ClickCount)
+	}
+	return cur
+}
+
+// linkListQuery collects the WHERE clauses and arguments of a link list request
+type linkListQuery struct {
+	where []string
+	args  []interface{}
+
+	sort   string
+	desc   bool
+	limit  int
+	cursor *linkCursor
+}
+
+// arg adds a query argument and returns its placeholder
+func (q *linkListQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// filter adds a condition links must match
+func (q *linkListQuery) filter(condition string) {
+	q.where = append(q.where, condition)
+}
+
+// whereSQL returns the WHERE clause for the collected filters
+func (q *linkListQuery) whereSQL() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+// parseQueryTime parses an optional RFC 3339 query parameter
+func parseQueryTime(c *fiber.Ctx, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return &t, nil
+}
+
+// linkListOptions are set by the endpoints built on the link list rather
+// than by the request
+type linkListOptions struct {
+	// status, if set, replaces the ?status= filter
+	status string
+
+	// defaultSort is the sort used without ?sort=; created_at if empty
+	defaultSort string
+}
+
+// parseLinkListQuery reads the pagination, sort and filter parameters of
+// GetAllLinks. Regular users only ever see their own links; admins see all
+// links and may filter by owner.
+func parseLinkListQuery(c *fiber.Ctx, userID string, isAdmin bool, opts linkListOptions) (*linkListQuery, error) {
+	defaultSort := opts.defaultSort
+	if defaultSort == "" {
+		defaultSort = "created_at"
+	}
+	q := &linkListQuery{
+		sort:  c.Query("sort", defaultSort),
+		desc:  c.Query("order", "desc") != "asc",
+		limit: c.QueryInt("limit", defaultLinkPageSize),
+	}
+	if _, ok := linkSortColumns[q.sort]; !ok {
+		return nil, fmt.Errorf("sort must be one of created_at, clicks or expires_at")
+	}
+	if q.limit < 1 || q.limit > maxLinkPageSize {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxLinkPageSize)
+	}
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := decodeLinkCursor(value, q.sort)
+		if err != nil {
+			return nil, err
+		}
+		q.cursor = cursor
+	}
+
+	if !isAdmin {
+		q.filter("l.user_id = " + q.arg(userID))
+	} else if owner := c.Query("owner"); owner != "" {
+		q.filter("l.user_id::text = " + q.arg(owner))
+	}
+
+	status := opts.status
+	if status == "" {
+		status = c.Query("status")
+	}
+	switch status {
+	case "":
+	case "active":
+		q.filter("l.is_active AND (l.expires_at IS NULL OR l.expires_at > NOW()) AND (l.active_from IS NULL OR l.active_from <= NOW())")
+	case "expired":
+		q.filter("l.expires_at <= NOW()")
+	case "deactivated":
+		q.filter("NOT l.is_active")
+	case "scheduled":
+		q.filter("l.active_from > NOW()")
+	default:
+		return nil, fmt.Errorf("status must be one of active, expired, deactivated or scheduled")
+	}
+
+	createdAfter, err := parseQueryTime(c, "created_after")
+	if err != nil {
+		return nil, err
+	}
+	if createdAfter != nil {
+		q.filter("l.created_at >= " + q.arg(*createdAfter))
+	}
+	createdBefore, err := parseQueryTime(c, "created_before")
+	if err != nil {
+		return nil, err
+	}
+	if createdBefore != nil {
+		q.filter("l.created_at < " + q.arg(*createdBefore))
+	}
+
+	// ?destination_domain= matches the destination host, including its subdomains
+	if domain := services.NormalizeDomain(c.Query("destination_domain")); domain != "" {
+		host := `lower(substring(l.long_url from '^[a-zA-Z][a-zA-Z0-9+.-]*://([^/:?#]+)'))`
+		placeholder := q.arg(domain)
+		q.filter(fmt.Sprintf("(%s = %s OR %s LIKE '%%.' || %s)", host, placeholder, host, placeholder))
+	}
+
+	if c.QueryBool("broken") {
+		q.filter("EXISTS (SELECT 1 FROM link_health h WHERE h.link_id = l.id AND h.is_broken)")
+	}
+
+	// ?tags=a,b matches links carrying all of the tags, or any of them with &tag_match=any
+	if c.Query("tags") != "" {
+		tags, err := services.NormalizeTagNames(strings.Split(c.Query("tags"), ","))
+		if err != nil {
+			return nil, err
+		}
+		tagged := `(SELECT COUNT(*) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+			WHERE lt.link_id = l.id AND t.name = ANY(` + q.arg(tags) + `))`
+		if c.Query("tag_match") == "any" {
+			q.filter(tagged + " > 0")
+		} else {
+			q.filter(tagged + " = " + q.arg(len(tags)))
+		}
+	}
+	return q, nil
+}
+
+// countSQL returns the query counting every link that matches the filters
+func (q *linkListQuery) countSQL() string {
+	return `SELECT COUNT(*) FROM links l` + q.whereSQL()
+}
+
+// pageSQL returns the query for one page of links. It fetches one link more
+// than the page size to tell whether there is a next page. Call it after
+// countSQL, since the cursor condition adds arguments.
+func (q *linkListQuery) pageSQL() string {
+	column := linkSortColumns[q.sort]
+	direction, comparison := "DESC", "<"
+	if !q.desc {
+		direction, comparison = "ASC", ">"
+	}
+
+	if q.cursor != nil {
+		cast := "::timestamptz"
+		if q.sort == "clicks" {
+			cast = "::bigint"
+		}
+		q.filter(fmt.Sprintf("(%s, l.id) %s (%s%s, %s)", column, comparison, q.arg(q.cursor.Value), cast, q.arg(q.cursor.ID)))
+	}
+
+	return fmt.Sprintf(`SELECT %s FROM links l%s ORDER BY %s %s, l.id %s LIMIT %d`,
+		linkInfoColumns, q.whereSQL(), column, direction, direction, q.limit+1)
+}
@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+// authorizeLink returns the key of the link addressed by the request (see
+// resolveAPILinkKey) after checking that the authenticated user owns the link or
+// is an admin
+func authorizeLink(c *fiber.Ctx) (string, error) {
+	shortCode := resolveAPILinkKey(c)
+
+	// Get user ID from context (set by NextAuth middleware)
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return "", fiber.NewError(fiber.StatusUnauthorized, "User authentication required")
+	}
+	isAdmin, _ := c.Locals("isAdmin").(bool)
+
+	exists, err := canAccessLink(shortCode, userID, isAdmin)
+	if err != nil || !exists {
+		return "", fiber.NewError(fiber.StatusNotFound, "Link not found or access denied")
+	}
+	return shortCode, nil
+}
+
+// UpdateLinkRequest defines the structure for the PATCH /api/user/links/:shortCode request body.
+// Fields left out of the request keep their current value; expiry accepts the
+// same expires_at / expires_in forms as ShortenRequest.
+type UpdateLinkRequest struct {
+	LongURL   *string    `json:"long_url,omitempty"`
+	Context   *string    `json:"context,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn string     `json:"expires_in,omitempty"`
+	Password  *string    `json:"password,omitempty"` // empty string removes the password
+
+	// RedirectType is 301, 302, 307 or 308; 0 reverts to the server default
+	RedirectType *int `json:"redirect_type,omitempty"`
+
+	// QueryPassthrough replaces the passthrough settings; {"enabled": false} turns it off
+	QueryPassthrough *QueryPassthrough `json:"query_passthrough,omitempty"`
+
+	// UTM replaces the link's UTM tags; an empty object removes them
+	UTM *services.UTMParams `json:"utm,omitempty"`
+
+	// Rules replaces the link's redirect rules; an empty array removes them
+	Rules *[]RedirectRule `json:"rules,omitempty"`
+
+	// ABTest replaces the link's A/B test; an object without variants removes it
+	ABTest *ABTest `json:"ab_test,omitempty"`
+
+	// ActiveFrom reschedules the link's activation; a past time makes it live now
+	ActiveFrom       *time.Time `json:"active_from,omitempty"`
+	PrelaunchURL     *string    `json:"prelaunch_url,omitempty"`     // empty string removes the holding URL
+	PrelaunchMessage *string    `json:"prelaunch_message,omitempty"` // empty string restores the default message
+
+	// Interstitial turns the forced preview page on or off
+	Interstitial *bool `json:"interstitial,omitempty"`
+
+	// Tags replaces the link's tags; an empty array removes them
+	Tags *[]string `json:"tags,omitempty"`
+
+	// ExpiredRedirectURL replaces the link's fallback for when it is used up;
+	// empty string removes it
+	ExpiredRedirectURL *string `json:"expired_redirect_url,omitempty"`
+
+	// AutoRenew turns renewal on click shortly before expiry on or off
+	AutoRenew *bool `json:"auto_renew,omitempty"`
+}
+
+// linkUpdate collects the SET clauses of an UPDATE links statement
+type linkUpdate struct {
+	sets []string
+	args []interface{}
+}
+
+// set assigns a value to a column
+func (u *linkUpdate) set(column string, value interface{}) {
+	u.args = append(u.args, value)
+	u.sets = append(u.sets, fmt.Sprintf("%s = $%d", column, len(u.args)))
+}
+
+// exec applies the collected changes to the link in tx, if there are any
+func (u *linkUpdate) exec(tx pgx.Tx, shortCode string) error {
+	if len(u.sets) == 0 {
+		return nil
+	}
+	args := append(u.args, shortCode)
+	updateSQL := fmt.Sprintf("UPDATE links SET %s WHERE short_code = $%d", strings.Join(u.sets, ", "), len(args))
+	_, err := tx.Exec(db.Ctx, updateSQL, args...)
+	return err
+}
+
+// clearStaleHealth drops the health result of a link whose destination is
+// about to change to longURL, so the monitor checks the new destination first
+// in its next round instead of reporting the old one's result
+func clearStaleHealth(tx pgx.Tx, shortCode, longURL string) error {
+	clearSQL := `
+		DELETE FROM link_health h
+		USING links l
+		WHERE h.link_id = l.id AND l.short_code = $1 AND l.long_url <> $2
+	`
+	_, err := tx.Exec(db.Ctx, clearSQL, shortCode, longURL)
+	return err
+}
+
+// UpdateLink changes the destination, context, expiry, password, redirect
+// type, query passthrough, UTM tags, redirect rules, A/B test, scheduled
+// activation, interstitial, tags, expired fallback or auto-renewal of an
+// existing link. Changes to the destination are recorded as a new revision.
+// Only the link's owner or an admin may update it.
+func UpdateLink(c *fiber.Ctx) error {
+	shortCode, err := authorizeLink(c)
+	if err != nil {
+		return err
+	}
+	userID, _ := c.Locals("userID").(string)
+
+	req := new(UpdateLinkRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	// Validate input and collect the changes
+	update := &linkUpdate{}
+
+	if req.LongURL != nil {
+		if err := validateURL(*req.LongURL); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		update.set("long_url", *req.LongURL)
+	}
+
+	if req.Context != nil {
+		if err := validateContext(*req.Context); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		update.set("context", *req.Context)
+	}
+
+	if req.Password != nil {
+		if err := validatePassword(*req.Password); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		hash, err := hashPassword(*req.Password)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not hash password.",
+			})
+		}
+		// An empty hash removes the password
+		update.set("password_hash", nullIfEmpty(hash))
+	}
+
+	// The activation time is checked against the expiry the link will have
+	// after the update, so the stored values fill in what the request leaves out
+	storedActiveFrom, expiresAt, err := getLinkSchedule(shortCode)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	expiryChanged := req.ExpiresAt != nil || req.ExpiresIn != ""
+
+	if expiryChanged {
+		// A nil expiry means the link never expires
+		expiresAt, err = resolveExpiration(req.ExpiresAt, req.ExpiresIn)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		update.set("expires_at", expiresAt)
+	}
+
+	if req.RedirectType != nil {
+		if err := validateRedirectType(*req.RedirectType); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		update.set("redirect_type", nullIfZero(*req.RedirectType))
+	}
+
+	if req.QueryPassthrough != nil {
+		if err := validateQueryPassthrough(req.QueryPassthrough); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		update.set("query_passthrough", jsonOrNull(req.QueryPassthrough))
+	}
+
+	if req.UTM != nil {
+		if err := req.UTM.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if req.UTM.IsEmpty() {
+			update.set("utm", nil)
+		} else {
+			update.set("utm", jsonOrNull(req.UTM))
+		}
+	}
+
+	if req.Rules != nil {
+		if err := validateRedirectRules(*req.Rules); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		update.set("redirect_rules", jsonOrNull(*req.Rules))
+	}
+
+	if req.ABTest != nil {
+		if len(req.ABTest.Variants) == 0 {
+			update.set("ab_test", nil)
+		} else if err := validateABTest(req.ABTest); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		} else {
+			update.set("ab_test", jsonOrNull(req.ABTest))
+		}
+	}
+
+	if expiryChanged || req.ActiveFrom != nil || req.PrelaunchURL != nil || req.PrelaunchMessage != nil {
+		var prelaunchURL, prelaunchMessage string
+		if req.PrelaunchURL != nil {
+			prelaunchURL = *req.PrelaunchURL
+		}
+		if req.PrelaunchMessage != nil {
+			prelaunchMessage = *req.PrelaunchMessage
+		}
+		// A stored activation time that has passed no longer matters
+		activeFrom := req.ActiveFrom
+		if activeFrom == nil && isPending(storedActiveFrom) {
+			activeFrom = storedActiveFrom
+		}
+		if err := validateActivation(activeFrom, expiresAt, prelaunchURL, prelaunchMessage); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if req.ActiveFrom != nil {
+			update.set("active_from", req.ActiveFrom)
+		}
+		if req.PrelaunchURL != nil {
+			update.set("prelaunch_url", nullIfEmpty(prelaunchURL))
+		}
+		if req.PrelaunchMessage != nil {
+			update.set("prelaunch_message", nullIfEmpty(prelaunchMessage))
+		}
+	}
+
+	if req.Interstitial != nil {
+		update.set("interstitial", *req.Interstitial)
+	}
+
+	if req.ExpiredRedirectURL != nil {
+		if *req.ExpiredRedirectURL != "" {
+			if err := validateURL(*req.ExpiredRedirectURL); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "expired_redirect_url: " + err.Error(),
+				})
+			}
+		}
+		update.set("expired_redirect_url", nullIfEmpty(*req.ExpiredRedirectURL))
+	}
+
+	if req.AutoRenew != nil {
+		update.set("auto_renew", *req.AutoRenew)
+	}
+
+	var tags []string
+	if req.Tags != nil {
+		tags, err = services.NormalizeTagNames(*req.Tags)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	tx, err := db.DB.Begin(db.Ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	defer tx.Rollback(db.Ctx)
+
+	if req.LongURL != nil {
+		if err := clearStaleHealth(tx, shortCode, *req.LongURL); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not update link.",
+			})
+		}
+	}
+
+	if err := update.exec(tx, shortCode); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not update link.",
+		})
+	}
+
+	if err := recordRevisions(tx, []string{shortCode}, userID, nil); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not record link revision.",
+		})
+	}
+
+	if req.Tags != nil {
+		if err := tagService.SetLinkTags(db.Ctx, tx, shortCode, tags); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not update link tags.",
+			})
+		}
+	}
+
+	if err := tx.Commit(db.Ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not update link.",
+		})
+	}
+
+	// Refresh the cached record so RedirectLink never serves the old destination
+	refreshLinkCache(shortCode)
+
+	link, err := getLinkInfo(shortCode)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch updated link",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Link updated successfully",
+		"link":    link,
+	})
+}
+
+// DeactivateLink disables a link without deleting it. Redirects answer 410
+// until the link is reactivated.
+func DeactivateLink(c *fiber.Ctx) error {
+	return setLinkActive(c, false)
+}
+
+// ReactivateLink re-enables a previously deactivated link
+func ReactivateLink(c *fiber.Ctx) error {
+	return setLinkActive(c, true)
+}
+
+// setLinkActive updates the is_active flag and refreshes the cached record
+func setLinkActive(c *fiber.Ctx, active bool) error {
+	shortCode, err := authorizeLink(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec(db.Ctx, "UPDATE links SET is_active = $2 WHERE short_code = $1", shortCode, active)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not update link status.",
+		})
+	}
+
+	refreshLinkCache(shortCode)
+
+	message := "Link deactivated successfully"
+	if active {
+		message = "Link reactivated successfully"
+	}
+	return c.JSON(fiber.Map{
+		"message":    message,
+		"short_code": shortCode,
+		"is_active":  active,
+	})
+}
+
+// Analytics retention choices for DeleteLink
+const (
+	retentionPurge   = "purge"   // delete the link's analytics with it (default)
+	retentionArchive = "archive" // move the link's analytics to analytics_archive
+)
+
+// DeleteLink permanently removes a link. The "analytics" query parameter
+// selects whether its click history is purged or archived.
+func DeleteLink(c *fiber.Ctx) error {
+	shortCode, err := authorizeLink(c)
+	if err != nil {
+		return err
+	}
+
+	retention := c.Query("analytics", retentionPurge)
+	if retention != retentionPurge && retention != retentionArchive {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "analytics must be either 'purge' or 'archive'",
+		})
+	}
+
+	tx, err := db.DB.Begin(db.Ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	defer tx.Rollback(db.Ctx)
+
+	if retention == retentionArchive {
+		archiveSQL := `
+			INSERT INTO analytics_archive (id, short_code, ip_address, user_agent, referrer, country, region, city, clicked_at,
+				query_params, utm_campaign, variant)
+			SELECT id, short_code, ip_address, user_agent, referrer, country, region, city, clicked_at,
+				query_params, utm_campaign, variant
+			FROM analytics
+			WHERE short_code = $1
+			ON CONFLICT (id) DO NOTHING
+		`
+		if _, err := tx.Exec(db.Ctx, archiveSQL, shortCode); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not archive link analytics.",
+			})
+		}
+	}
+
+	// Analytics rows are removed by the ON DELETE CASCADE foreign key
+	if _, err := tx.Exec(db.Ctx, "DELETE FROM links WHERE short_code = $1", shortCode); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not delete link.",
+		})
+	}
+
+	if err := tx.Commit(db.Ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not delete link.",
+		})
+	}
+
+	evictLink(shortCode)
+	db.RDB.Del(db.Ctx, clickCounterKey(shortCode)).Err()
+
+	return c.JSON(fiber.Map{
+		"message":    "Link deleted successfully",
+		"short_code": shortCode,
+		"analytics":  retention,
+	})
+}
+
+// nullIfEmpty maps an empty string to SQL NULL
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// nullIfZero maps zero to SQL NULL
+func nullIfZero(value int) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
+
+// jsonOrNull encodes a value for a JSONB column, mapping nil pointers, slices
+// and maps to SQL NULL
+func jsonOrNull(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if rv.IsNil() || (rv.Kind() != reflect.Ptr && rv.Len() == 0) {
+			return nil
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"html/template"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// previewCookieDuration is how long the "continue" button of a preview page
+// may be followed without seeing the preview again
+const previewCookieDuration = 5 * time.Minute
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link preview - GoChop</title>
+<style>
+body{font-family:system-ui,sans-serif;background:#f5f5f5;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0}
+main{background:#fff;padding:2rem;border-radius:8px;box-shadow:0 2px 8px rgba(0,0,0,.1);width:100%;max-width:480px}
+dt{font-weight:600;margin-top:.75rem}
+dd{margin:0;word-break:break-all}
+a.button{display:block;text-align:center;background:#222;color:#fff;text-decoration:none;padding:.6rem;margin-top:1.5rem;border-radius:4px;font-size:1rem}
+</style>
+</head>
+<body>
+<main>
+<h1>You are about to visit</h1>
+<dl>
+{{if .Protected}}<dt>Destination</dt><dd>Hidden, this link is password protected</dd>
+{{else}}<dt>Domain</dt><dd>{{.Domain}}</dd>
+<dt>Destination</dt><dd>{{.Destination}}</dd>
+{{end}}{{if .Context}}<dt>Description</dt><dd>{{.Context}}</dd>
+{{end}}<dt>Created</dt><dd>{{.CreatedAt.Format "January 2, 2006"}}</dd>
+</dl>
+<a class="button" href="{{.ContinueURL}}" rel="nofollow">Continue</a>
+</main>
+</body>
+</html>`))
+
+// previewCookieName returns the cookie that lets a visitor continue past the preview
+func previewCookieName(shortCode string) string {
+	return "gochop_preview_" + publicCode(shortCode)
+}
+
+// passedInterstitial reports whether the visitor is following the "continue"
+// button of a preview page. The cookie is cleared so the next visit shows the
+// preview again.
+func passedInterstitial(c *fiber.Ctx, shortCode string) bool {
+	if c.Cookies(previewCookieName(shortCode)) == "" {
+		return false
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:    previewCookieName(shortCode),
+		Path:    "/" + publicCode(shortCode),
+		Expires: time.Unix(0, 0),
+	})
+	return true
+}
+
+// renderPreviewPage shows the link's destination, domain, context and creation
+// date with a "continue" button. Showing the page is not a click, so it is
+// excluded from analytics; following the button is counted as usual.
+func renderPreviewPage(c *fiber.Ctx, shortCode string, link *cachedLink) error {
+	c.Locals("skipAnalytics", true)
+
+	// The continue button goes through the short link again, keeping the
+	// query string so passthrough still applies
+	continueURL := "/" + publicCode(shortCode)
+	if query := string(c.Request().URI().QueryString()); query != "" {
+		continueURL += "?" + query
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     previewCookieName(shortCode),
+		Value:    "1",
+		Path:     "/" + publicCode(shortCode),
+		Expires:  time.Now().Add(previewCookieDuration),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	var domain string
+	if parsed, err := url.Parse(link.LongURL); err == nil {
+		domain = parsed.Hostname()
+	}
+
+	c.Status(fiber.StatusOK)
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	c.Set("Cache-Control", "no-store")
+	return previewPage.Execute(c.Response().BodyWriter(), fiber.Map{
+		"Protected":   link.PasswordHash != "",
+		"Destination": link.LongURL,
+		"Domain":      domain,
+		"Context":     link.Context,
+		"CreatedAt":   link.CreatedAt,
+		"ContinueURL": continueURL,
+	})
+}
+
+// PreviewLink serves the preview page for "/:shortCode+" without redirecting
+func PreviewLink(c *fiber.Ctx) error {
+	shortCode := resolveLinkKey(c)
+	c.Locals("skipAnalytics", true)
+
+	link, err := loadLink(shortCode)
+	if err != nil {
+		return serveNotFound(c, shortCode)
+	}
+	if handled, err := serveUnavailable(c, shortCode, link); handled {
+		return err
+	}
+
+	return renderPreviewPage(c, shortCode, link)
+}
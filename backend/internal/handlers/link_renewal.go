@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"gochop/backend/internal/db"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RenewLinkRequest defines the body for POST /api/user/links/:shortCode/renew.
+// The new expiry takes the same expires_at / expires_in forms as
+// ShortenRequest; without either the link gets the default lifetime.
+type RenewLinkRequest struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn string     `json:"expires_in,omitempty"`
+
+	// AutoRenew optionally turns auto-renewal on or off at the same time
+	AutoRenew *bool `json:"auto_renew,omitempty"`
+}
+
+// autoRenewDebounce is how long after an auto-renewal further clicks leave
+// the link's expiry alone
+const autoRenewDebounce = time.Hour
+
+// autoRenewKey returns the Redis key marking a link as recently auto-renewed
+func autoRenewKey(shortCode string) string {
+	return "autorenew:" + shortCode
+}
+
+// autoRenewLink gives a link that was clicked shortly before expiring the
+// default lifetime again, counted from now. A burst of clicks renews it once
+// per autoRenewDebounce, across instances. It is meant to run in its own
+// goroutine, off the redirect's path.
+func autoRenewLink(shortCode string) {
+	first, err := db.RDB.SetNX(db.Ctx, autoRenewKey(shortCode), 1, autoRenewDebounce).Result()
+	if err != nil || !first {
+		return
+	}
+	expiresAt, err := resolveExpiration(nil, "")
+	if err != nil || expiresAt == nil {
+		return
+	}
+	tag, err := db.DB.Exec(db.Ctx, `
+		UPDATE links SET expires_at = $2
+		WHERE short_code = $1 AND auto_renew AND expires_at < $2`, shortCode, expiresAt)
+	if err == nil && tag.RowsAffected() > 0 {
+		refreshLinkCache(shortCode)
+	}
+}
+
+// GetExpiredLinks lists the current user's expired links, or all expired
+// links for admins, most recently expired first. It takes the same paging and
+// filter parameters as GetAllLinks.
+func GetExpiredLinks(c *fiber.Ctx) error {
+	return listLinks(c, linkListOptions{status: "expired", defaultSort: "expires_at"})
+}
+
+// RenewLink gives an expired or expiring link a new expiry. An expired link
+// keeps its row and so its short code, which cannot be claimed by another
+// link in the meantime; only a deleted link is gone for good. Links that have
+// reached their click cap cannot be renewed.
+// Only the link's owner or an admin may renew it.
+func RenewLink(c *fiber.Ctx) error {
+	shortCode, err := authorizeLink(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(RenewLinkRequest)
+	if err := c.BodyParser(req); err != nil && len(c.Body()) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	expiresAt, err := resolveExpiration(req.ExpiresAt, req.ExpiresIn)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Renewal only moves the expiry, so it cannot revive a link that has used
+	// up its clicks or push the expiry before a scheduled activation
+	current, err := getLinkInfo(shortCode)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if current.RemainingClicks != nil && *current.RemainingClicks == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Link has reached its click limit; renewing would not make it available again",
+		})
+	}
+	if isPending(current.ActiveFrom) {
+		if err := validateActivation(current.ActiveFrom, expiresAt, "", ""); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	update := &linkUpdate{}
+	update.set("expires_at", expiresAt)
+	if req.AutoRenew != nil {
+		update.set("auto_renew", *req.AutoRenew)
+	}
+
+	tx, err := db.DB.Begin(db.Ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	defer tx.Rollback(db.Ctx)
+
+	err = update.exec(tx, shortCode)
+	if err == nil {
+		err = tx.Commit(db.Ctx)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not renew link.",
+		})
+	}
+
+	// Expired links are evicted from Redis, so this puts the link back in the cache
+	refreshLinkCache(shortCode)
+
+	link, err := getLinkInfo(shortCode)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch updated link",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Link renewed successfully",
+		"link":    link,
+	})
+}
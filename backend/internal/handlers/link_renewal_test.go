@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"gochop/backend/internal/db"
+	"testing"
+)
+
+func TestAutoRenewLinkIsDebounced(t *testing.T) {
+	server := useTestRedis(t)
+	server.Set(autoRenewKey("renewed"), "1")
+
+	// A renewal within the debounce window must not reach the database
+	previous := db.DB
+	db.DB = nil
+	defer func() { db.DB = previous }()
+
+	autoRenewLink("renewed")
+}
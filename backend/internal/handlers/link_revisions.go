@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gochop/backend/internal/db"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+// LinkRevision is a link's destination as of a point in time. RollbackOf is
+// set when the revision was created by rolling back to an earlier one.
+type LinkRevision struct {
+	Revision   int            `json:"revision"`
+	LongURL    string         `json:"long_url"`
+	Rules      []RedirectRule `json:"rules"`
+	ABTest     *ABTest        `json:"ab_test"`
+	ChangedBy  *string        `json:"changed_by"`
+	RollbackOf *int           `json:"rollback_of"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// RevisionClickData represents the clicks a link got while a revision was
+// current. EndedAt is null for the current revision.
+type RevisionClickData struct {
+	Revision  int        `json:"revision"`
+	LongURL   string     `json:"long_url"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Clicks    int        `json:"clicks"`
+}
+
+// recordRevisionsSQL snapshots the destination of the links with the given
+// keys as their next revision, skipping links whose latest revision already
+// matches
+const recordRevisionsSQL = `
+	INSERT INTO link_revisions (link_id, revision, long_url, redirect_rules, ab_test, changed_by, rollback_of)
+	SELECT l.id, COALESCE(latest.revision, 0) + 1, l.long_url, l.redirect_rules, l.ab_test, NULLIF($2, '')::uuid, $3
+	FROM links l
+	LEFT JOIN LATERAL (
+		SELECT r.revision, r.long_url, r.redirect_rules, r.ab_test
+		FROM link_revisions r
+		WHERE r.link_id = l.id
+		ORDER BY r.revision DESC
+		LIMIT 1
+	) latest ON true
+	WHERE l.short_code = ANY($1)
+		AND (latest.revision IS NULL
+			OR latest.long_url IS DISTINCT FROM l.long_url
+			OR latest.redirect_rules IS DISTINCT FROM l.redirect_rules
+			OR latest.ab_test IS DISTINCT FROM l.ab_test)
+`
+
+// recordRevisions records the current destination of the given links as a
+// new revision by the given user, if it changed
+func recordRevisions(tx pgx.Tx, shortCodes []string, userID string, rollbackOf *int) error {
+	_, err := tx.Exec(db.Ctx, recordRevisionsSQL, shortCodes, userID, rollbackOf)
+	return err
+}
+
+// validateRevisionTargets checks a stored revision's destination, redirect
+// rules and A/B test as if they were being saved now
+func validateRevisionTargets(longURL string, rulesJSON, abTestJSON []byte) error {
+	if err := validateURL(longURL); err != nil {
+		return err
+	}
+	var rules []RedirectRule
+	if len(rulesJSON) > 0 {
+		if err := json.Unmarshal(rulesJSON, &rules); err != nil {
+			return err
+		}
+	}
+	if err := validateRedirectRules(rules); err != nil {
+		return err
+	}
+	var abTest *ABTest
+	if len(abTestJSON) > 0 {
+		if err := json.Unmarshal(abTestJSON, &abTest); err != nil {
+			return err
+		}
+	}
+	return validateABTest(abTest)
+}
+
+// getRevisions returns a link's revisions, newest first
+func getRevisions(shortCode string) ([]LinkRevision, error) {
+	query := `
+		SELECT r.revision, r.long_url, r.redirect_rules, r.ab_test, r.changed_by::text, r.rollback_of, r.created_at
+		FROM link_revisions r
+		JOIN links l ON l.id = r.link_id
+		WHERE l.short_code = $1
+		ORDER BY r.revision DESC
+	`
+	rows, err := db.DB.Query(db.Ctx, query, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []LinkRevision{}
+	for rows.Next() {
+		var revision LinkRevision
+		var rules, abTest []byte
+		if err := rows.Scan(&revision.Revision, &revision.LongURL, &rules, &abTest,
+			&revision.ChangedBy, &revision.RollbackOf, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(rules) > 0 {
+			json.Unmarshal(rules, &revision.Rules)
+		}
+		if len(abTest) > 0 {
+			json.Unmarshal(abTest, &revision.ABTest)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// getClicksByRevision counts a link's clicks per revision period
+func getClicksByRevision(shortCode string) ([]RevisionClickData, error) {
+	query := `
+		WITH periods AS (
+			SELECT r.revision, r.long_url, r.created_at AS started_at,
+				LEAD(r.created_at) OVER (ORDER BY r.revision) AS ended_at
+			FROM link_revisions r
+			JOIN links l ON l.id = r.link_id
+			WHERE l.short_code = $1
+		)
+		SELECT p.revision, p.long_url, p.started_at, p.ended_at,
+			(SELECT COUNT(*) FROM analytics a
+			 WHERE a.short_code = $1 AND a.clicked_at >= p.started_at
+				AND (p.ended_at IS NULL OR a.clicked_at < p.ended_at)) AS clicks
+		FROM periods p
+		ORDER BY p.revision
+	`
+	rows, err := db.DB.Query(db.Ctx, query, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var periods []RevisionClickData
+	for rows.Next() {
+		var period RevisionClickData
+		if err := rows.Scan(&period.Revision, &period.LongURL, &period.StartedAt, &period.EndedAt, &period.Clicks); err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	return periods, rows.Err()
+}
+
+// GetLinkRevisions lists the destinations a link has had, newest first.
+// Only the link's owner or an admin may see them.
+func GetLinkRevisions(c *fiber.Ctx) error {
+	shortCode, err := authorizeLink(c)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := getRevisions(shortCode)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch link revisions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"short_code": publicCode(shortCode),
+		"revisions":  revisions,
+	})
+}
+
+// RollbackLink restores the destination of an earlier revision, recording
+// the rollback as a new revision, and re-primes the cached record so
+// redirects follow it immediately.
+// Only the link's owner or an admin may roll it back.
+func RollbackLink(c *fiber.Ctx) error {
+	shortCode, err := authorizeLink(c)
+	if err != nil {
+		return err
+	}
+	userID, _ := c.Locals("userID").(string)
+
+	revision, err := c.ParamsInt("revision")
+	if err != nil || revision < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid revision",
+		})
+	}
+
+	// The destination may have been blocked since it was last used
+	var longURL string
+	var rulesJSON, abTestJSON []byte
+	err = db.DB.QueryRow(db.Ctx, `
+		SELECT r.long_url, r.redirect_rules, r.ab_test FROM link_revisions r
+		JOIN links l ON l.id = r.link_id
+		WHERE l.short_code = $1 AND r.revision = $2
+	`, shortCode, revision).Scan(&longURL, &rulesJSON, &abTestJSON)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Revision not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	// The restored destinations are checked against the current URL policy,
+	// since domains may have been blocked after the revision was saved
+	if err := validateRevisionTargets(longURL, rulesJSON, abTestJSON); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot roll back: " + err.Error(),
+		})
+	}
+
+	tx, err := db.DB.Begin(db.Ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	defer tx.Rollback(db.Ctx)
+
+	if err := clearStaleHealth(tx, shortCode, longURL); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not roll back link.",
+		})
+	}
+
+	rollbackSQL := `
+		UPDATE links l
+		SET long_url = r.long_url, redirect_rules = r.redirect_rules, ab_test = r.ab_test
+		FROM link_revisions r
+		WHERE r.link_id = l.id AND l.short_code = $1 AND r.revision = $2
+	`
+	if _, err := tx.Exec(db.Ctx, rollbackSQL, shortCode, revision); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not roll back link.",
+		})
+	}
+	if err := recordRevisions(tx, []string{shortCode}, userID, &revision); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not record link revision.",
+		})
+	}
+	if err := tx.Commit(db.Ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not roll back link.",
+		})
+	}
+
+	// Re-prime the cached record so RedirectLink never serves the old destination
+	refreshLinkCache(shortCode)
+
+	link, err := getLinkInfo(shortCode)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch updated link",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Link rolled back successfully",
+		"link":    link,
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"gochop/backend/internal/db"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultPrelaunchMessage is shown before a scheduled link goes live, unless
+// the link sets its own message or holding URL
+const defaultPrelaunchMessage = "This link is not available yet."
+
+// validateActivation checks a link's scheduled activation settings
+func validateActivation(activeFrom, expiresAt *time.Time, prelaunchURL, prelaunchMessage string) error {
+	if activeFrom != nil && expiresAt != nil && !activeFrom.Before(*expiresAt) {
+		return fmt.Errorf("active_from must be before the link expires")
+	}
+	if prelaunchURL != "" {
+		if err := validateURL(prelaunchURL); err != nil {
+			return fmt.Errorf("prelaunch_url: %v", err)
+		}
+	}
+	if len(prelaunchMessage) > 200 {
+		return fmt.Errorf("prelaunch_message must be less than 200 characters")
+	}
+	return nil
+}
+
+// getLinkSchedule returns a link's stored activation time and expiry
+func getLinkSchedule(shortCode string) (activeFrom, expiresAt *time.Time, err error) {
+	err = db.DB.QueryRow(db.Ctx, "SELECT active_from, expires_at FROM links WHERE short_code = $1", shortCode).
+		Scan(&activeFrom, &expiresAt)
+	return activeFrom, expiresAt, err
+}
+
+// isPending reports whether a link scheduled for activeFrom has not gone live yet
+func isPending(activeFrom *time.Time) bool {
+	return activeFrom != nil && time.Now().Before(*activeFrom)
+}
+
+// servePrelaunch answers requests for a link that is not live yet, either by
+// sending the visitor to the link's holding URL or with a "not yet available"
+// response. Neither is a click, and neither may be cached by browsers or
+// proxies past the activation moment.
+func servePrelaunch(c *fiber.Ctx, link *cachedLink) error {
+	c.Locals("skipAnalytics", true)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	if link.PrelaunchURL != "" {
+		return c.Redirect(link.PrelaunchURL, fiber.StatusFound)
+	}
+
+	message := link.PrelaunchMessage
+	if message == "" {
+		message = defaultPrelaunchMessage
+	}
+	retryAfter := int(time.Until(*link.ActiveFrom).Seconds()) + 1
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	c.Set("X-Available-From", link.ActiveFrom.UTC().Format(http.TimeFormat))
+	return c.Status(fiber.StatusServiceUnavailable).SendString(message)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"gochop/backend/internal/db"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchTerms     = 10
+)
+
+var searchTermSplitter = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// SearchResult is a link matching a search, with its rank and the matched
+// fields. Highlights hold HTML-escaped field values with matches wrapped in
+// <mark> tags.
+type SearchResult struct {
+	Link       LinkInfo          `json:"link"`
+	Rank       float32           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// searchTerms splits a free-text query into lower-cased words
+func searchTerms(query string) []string {
+	var terms []string
+	for _, term := range searchTermSplitter.Split(strings.ToLower(query), -1) {
+		if term != "" && len(terms) < maxSearchTerms {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// prefixTSQuery builds a tsquery matching all terms as word prefixes. Terms
+// only contain letters and digits, so they cannot inject tsquery syntax.
+func prefixTSQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+// isWordStart reports whether position i of s starts a word
+func isWordStart(s string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(s[:i])
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev)
+}
+
+// highlight HTML-escapes text and wraps the words starting with one of the
+// terms in <mark> tags, mirroring the prefix matching of the search. It
+// reports false when nothing matched.
+func highlight(text string, terms []string) (string, bool) {
+	var b strings.Builder
+	matched := false
+	last := 0
+	for i := 0; i < len(text); {
+		end := 0
+		if isWordStart(text, i) {
+			for _, term := range terms {
+				if i+len(term) <= len(text) && strings.EqualFold(text[i:i+len(term)], term) && len(term) > end {
+					end = len(term)
+				}
+			}
+		}
+		if end == 0 {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:i]))
+		b.WriteString("<mark>" + html.EscapeString(text[i:i+end]) + "</mark>")
+		matched = true
+		i += end
+		last = i
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String(), matched
+}
+
+// SearchLinks ranks the caller's links (all links for admins) against a
+// free-text query in ?q=, matching short code, destination, destination
+// domain and context
+func SearchLinks(c *fiber.Ctx) error {
+	// Get user ID from context (set by NextAuth middleware)
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+	isAdmin, _ := c.Locals("isAdmin").(bool)
+
+	terms := searchTerms(c.Query("q"))
+	if len(terms) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Search query is required",
+		})
+	}
+	limit := c.QueryInt("limit", defaultSearchLimit)
+	if limit < 1 || limit > maxSearchLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit),
+		})
+	}
+
+	args := []interface{}{prefixTSQuery(terms), limit}
+	where := "l.search_vector @@ to_tsquery('simple', $1)"
+	if !isAdmin {
+		args = append(args, userID)
+		where += " AND l.user_id = $3"
+	}
+	query := `
+		SELECT ` + linkInfoColumns + `, ts_rank(l.search_vector, to_tsquery('simple', $1)) as rank
+		FROM links l
+		WHERE ` + where + `
+		ORDER BY rank DESC, l.created_at DESC
+		LIMIT $2
+	`
+	rows, err := db.DB.Query(db.Ctx, query, args...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not search links",
+		})
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		link, err := scanLinkInfo(rankedRow{rows, &result.Rank})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not scan link data",
+			})
+		}
+		result.Link = *link
+
+		result.Highlights = make(map[string]string)
+		fields := map[string]string{"short_code": link.ShortCode, "long_url": link.LongURL, "context": link.Context}
+		for name, value := range fields {
+			if marked, ok := highlight(value, terms); ok {
+				result.Highlights[name] = marked
+			}
+		}
+		results = append(results, result)
+	}
+	links := make([]*LinkInfo, len(results))
+	for i := range results {
+		links[i] = &results[i].Link
+	}
+	fillRemainingClicks(links)
+
+	return c.JSON(fiber.Map{
+		"query":   c.Query("q"),
+		"results": results,
+	})
+}
+
+// rankedRow scans a row selected with linkInfoColumns followed by a rank column
+type rankedRow struct {
+	row  pgx.Row
+	rank *float32
+}
+
+// Scan reads the LinkInfo columns and the trailing rank
+func (r rankedRow) Scan(dest ...interface{}) error {
+	return r.row.Scan(append(dest, r.rank)...)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", nil},
+		{"  Summer Sale ", []string{"summer", "sale"}},
+		{"example.com/promo", []string{"example", "com", "promo"}},
+		{"a & b | !c:*", []string{"a", "b", "c"}},
+		{"Größe café", []string{"größe", "café"}},
+		{strings.Repeat("x ", maxSearchTerms+5), strings.Fields(strings.Repeat("x ", maxSearchTerms))},
+	}
+	for _, tt := range tests {
+		if got := searchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPrefixTSQuery(t *testing.T) {
+	if got := prefixTSQuery([]string{"summer", "sale"}); got != "summer:* & sale:*" {
+		t.Errorf("prefixTSQuery = %q", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		terms       []string
+		want        string
+		wantMatched bool
+	}{
+		{"no match", "Spring launch", []string{"sale"}, "Spring launch", false},
+		{"word prefix", "Summer sale", []string{"sal"}, "Summer <mark>sal</mark>e", true},
+		{"case insensitive", "SUMMER Sale", []string{"summer"}, "<mark>SUMMER</mark> Sale", true},
+		{"only word starts", "wholesale", []string{"sale"}, "wholesale", false},
+		{"after punctuation", "https://example.com/sale", []string{"sale", "example"}, "https://<mark>example</mark>.com/<mark>sale</mark>", true},
+		{"longest term wins", "salesforce", []string{"sale", "sales"}, "<mark>sales</mark>force", true},
+		{"every occurrence", "sale, sale", []string{"sale"}, "<mark>sale</mark>, <mark>sale</mark>", true},
+		{"escapes html", `<b>deal</b> & "promo"`, []string{"deal"}, "&lt;b&gt;<mark>deal</mark>&lt;/b&gt; &amp; &#34;promo&#34;", true},
+		{"escapes inside marks", "a&b", []string{"a"}, "<mark>a</mark>&amp;b", true},
+		{"unicode", "Café Größe", []string{"grö"}, "Café <mark>Grö</mark>ße", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, matched := highlight(tt.text, tt.terms)
+			if got != tt.want || matched != tt.wantMatched {
+				t.Errorf("highlight = %q, %v; want %q, %v", got, matched, tt.want, tt.wantMatched)
+			}
+		})
+	}
+}
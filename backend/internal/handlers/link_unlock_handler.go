@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gochop/backend/internal/db"
+	"gochop/backend/internal/middleware"
+	"html/template"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	unlockCookieDuration  = 1 * time.Hour    // how long an unlocked link skips the prompt
+	maxUnlockAttempts     = 5                // attempts allowed per visitor and link per window
+	maxLinkUnlockAttempts = 50               // attempts allowed per link from all visitors per window
+	unlockAttemptWindow   = 15 * time.Minute // throttling window for wrong passwords
+)
+
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required - GoChop</title>
+<style>
+body{font-family:system-ui,sans-serif;background:#f5f5f5;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0}
+form{background:#fff;padding:2rem;border-radius:8px;box-shadow:0 2px 8px rgba(0,0,0,.1);width:100%;max-width:320px}
+input,button{width:100%;padding:.6rem;margin-top:.75rem;box-sizing:border-box;font-size:1rem}
+.error{color:#c00;margin-top:.75rem}
+</style>
+</head>
+<body>
+<form method="POST" action="/{{.ShortCode}}">
+<h1>Password required</h1>
+<p>This link is password protected.</p>
+<input type="password" name="password" placeholder="Password" autofocus required>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<button type="submit">Unlock</button>
+</form>
+</body>
+</html>`))
+
+// validatePassword checks if the provided link password is valid
+func validatePassword(password string) error {
+	if password == "" {
+		return nil // No password is allowed
+	}
+	if len(password) < 4 || len(password) > 72 {
+		return fmt.Errorf("password must be between 4 and 72 characters")
+	}
+	return nil
+}
+
+// hashPassword hashes a link password with bcrypt. An empty password yields an
+// empty hash, meaning the link is not protected.
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// getUnlockSecret returns the key used to sign unlock cookies
+func getUnlockSecret() string {
+	if secret := os.Getenv("LINK_UNLOCK_SECRET"); secret != "" {
+		return secret
+	}
+	return os.Getenv("NEXTAUTH_SECRET")
+}
+
+// unlockCookieName returns the cookie that remembers an unlocked link
+func unlockCookieName(shortCode string) string {
+	return "gochop_unlock_" + publicCode(shortCode)
+}
+
+// signUnlock signs a short code and expiry. The password hash is part of the
+// signature, so changing a link's password invalidates existing cookies.
+func signUnlock(shortCode, passwordHash string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(getUnlockSecret()))
+	fmt.Fprintf(mac, "%s|%d|%s", shortCode, expires, passwordHash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// setUnlockCookie issues a short-lived signed cookie for an unlocked link
+func setUnlockCookie(c *fiber.Ctx, shortCode string, link *cachedLink) {
+	if getUnlockSecret() == "" {
+		return // Without a secret we cannot sign cookies, so always prompt
+	}
+	expires := time.Now().Add(unlockCookieDuration)
+	c.Cookie(&fiber.Cookie{
+		Name:     unlockCookieName(shortCode),
+		Value:    strconv.FormatInt(expires.Unix(), 10) + "." + signUnlock(shortCode, link.PasswordHash, expires.Unix()),
+		Path:     "/" + publicCode(shortCode),
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// hasValidUnlockCookie reports whether the visitor recently unlocked the link
+func hasValidUnlockCookie(c *fiber.Ctx, shortCode string, link *cachedLink) bool {
+	if getUnlockSecret() == "" {
+		return false
+	}
+	expiresStr, signature, ok := strings.Cut(c.Cookies(unlockCookieName(shortCode)), ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	expected := signUnlock(shortCode, link.PasswordHash, expires)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// renderUnlockPage serves the password form. Showing the form is not a click,
+// so it is excluded from analytics.
+func renderUnlockPage(c *fiber.Ctx, status int, shortCode, errorMessage string) error {
+	c.Locals("skipAnalytics", true)
+	c.Status(status)
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	c.Set("Cache-Control", "no-store")
+	return unlockPage.Execute(c.Response().BodyWriter(), fiber.Map{
+		"ShortCode": publicCode(shortCode),
+		"Error":     errorMessage,
+	})
+}
+
+// unlockAttemptsKey returns the Redis key counting a visitor's unlock
+// attempts for a link, or everyone's attempts when clientIP is empty
+func unlockAttemptsKey(shortCode, clientIP string) string {
+	if clientIP == "" {
+		return "unlock_attempts:" + shortCode
+	}
+	return "unlock_attempts:" + shortCode + ":" + clientIP
+}
+
+// countUnlockAttempt records an unlock attempt and reports whether it is
+// within the limits. Counting per client IP keeps one visitor from locking
+// everyone else out, while the per-link limit caps guessing from many IPs.
+// The attempt is counted before the password is checked so parallel guesses
+// cannot all slip in under the limit.
+func countUnlockAttempt(shortCode, clientIP string) (bool, error) {
+	visitorKey := unlockAttemptsKey(shortCode, clientIP)
+	allKey := unlockAttemptsKey(shortCode, "")
+
+	pipe := db.RDB.TxPipeline()
+	visitorAttempts := pipe.Incr(db.Ctx, visitorKey)
+	pipe.Expire(db.Ctx, visitorKey, unlockAttemptWindow)
+	allAttempts := pipe.Incr(db.Ctx, allKey)
+	pipe.Expire(db.Ctx, allKey, unlockAttemptWindow)
+	if _, err := pipe.Exec(db.Ctx); err != nil {
+		return false, err
+	}
+	return visitorAttempts.Val() <= maxUnlockAttempts && allAttempts.Val() <= maxLinkUnlockAttempts, nil
+}
+
+// UnlockLink verifies the password submitted from the unlock form and, if it
+// matches, redirects to the destination and remembers the unlock in a cookie.
+func UnlockLink(c *fiber.Ctx) error {
+	shortCode := resolveLinkKey(c)
+	c.Locals("linkKey", shortCode)
+
+	link, err := loadLink(shortCode)
+	if err != nil {
+		return serveNotFound(c, shortCode)
+	}
+	if link.PasswordHash == "" {
+		c.Locals("skipAnalytics", true)
+		return c.Redirect("/"+publicCode(shortCode), fiber.StatusSeeOther)
+	}
+
+	// Throttle attempts per visitor and link, across instances. Without Redis
+	// the attempts cannot be counted, so no password is checked.
+	clientIP := middleware.GetTrustedClientIP(c)
+	allowed, err := countUnlockAttempt(shortCode, clientIP)
+	if err != nil {
+		return renderUnlockPage(c, fiber.StatusServiceUnavailable, shortCode, "The password cannot be checked right now. Please try again later.")
+	}
+	if !allowed {
+		return renderUnlockPage(c, fiber.StatusTooManyRequests, shortCode, "Too many attempts. Please try again later.")
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(c.FormValue("password"))) != nil {
+		return renderUnlockPage(c, fiber.StatusUnauthorized, shortCode, "Incorrect password.")
+	}
+
+	db.RDB.Del(db.Ctx, unlockAttemptsKey(shortCode, clientIP))
+	setUnlockCookie(c, shortCode, link)
+	c.Locals("linkUnlocked", true)
+	return serveRedirect(c, shortCode, link)
+}
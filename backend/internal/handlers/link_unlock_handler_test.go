@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCountUnlockAttempt(t *testing.T) {
+	useTestRedis(t)
+
+	for i := 1; i <= maxUnlockAttempts+1; i++ {
+		allowed, err := countUnlockAttempt("abc123", "203.0.113.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i <= maxUnlockAttempts; allowed != want {
+			t.Fatalf("attempt %d from one visitor: allowed = %v, want %v", i, allowed, want)
+		}
+	}
+
+	// Other visitors keep their own allowance until the link's limit is used up
+	allowed, _ := countUnlockAttempt("abc123", "203.0.113.2")
+	if !allowed {
+		t.Fatal("another visitor was locked out")
+	}
+	for i := maxUnlockAttempts + 3; i <= maxLinkUnlockAttempts+1; i++ {
+		allowed, err := countUnlockAttempt("abc123", fmt.Sprintf("198.51.100.%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i <= maxLinkUnlockAttempts; allowed != want {
+			t.Fatalf("attempt %d on the link: allowed = %v, want %v", i, allowed, want)
+		}
+	}
+}
+
+func TestCountUnlockAttemptRedisDown(t *testing.T) {
+	server := useTestRedis(t)
+	server.Close()
+
+	if allowed, err := countUnlockAttempt("abc123", "203.0.113.1"); err == nil || allowed {
+		t.Errorf("allowed = %v, err = %v; want an error and no attempt allowed", allowed, err)
+	}
+}
+
+func TestUnlockedRedirectDoesNotResendForm(t *testing.T) {
+	link := &cachedLink{LongURL: "https://example.com/", IsActive: true, RedirectType: fiber.StatusPermanentRedirect}
+	app := fiber.New()
+	serve := func(c *fiber.Ctx) error {
+		c.Locals("linkUnlocked", c.Method() == fiber.MethodPost)
+		return serveRedirect(c, "abc123", link)
+	}
+	app.Get("/:shortCode", serve)
+	app.Post("/:shortCode", serve)
+
+	tests := []struct {
+		method string
+		want   int
+	}{
+		{fiber.MethodGet, fiber.StatusPermanentRedirect},
+		{fiber.MethodPost, fiber.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/abc123", strings.NewReader("password=secret"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.method, resp.StatusCode, tt.want)
+		}
+		if location := resp.Header.Get("Location"); location != link.LongURL {
+			t.Errorf("%s: Location = %q, want %q", tt.method, location, link.LongURL)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Conflict policies for a forwarded parameter the destination already has
+const (
+	passthroughKeep     = "keep"     // keep the destination's value
+	passthroughOverride = "override" // replace it with the incoming value
+	passthroughAppend   = "append"   // send both values
+)
+
+// QueryPassthrough configures forwarding of the short URL's query parameters
+// to the destination. Policy is the default conflict policy; Params overrides
+// it for individual parameter names.
+type QueryPassthrough struct {
+	Enabled bool              `json:"enabled"`
+	Policy  string            `json:"policy,omitempty"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// validatePassthroughPolicy checks if the provided conflict policy is valid
+func validatePassthroughPolicy(policy string) error {
+	switch policy {
+	case "", passthroughKeep, passthroughOverride, passthroughAppend:
+		return nil
+	}
+	return fmt.Errorf("passthrough policy must be one of keep, override or append")
+}
+
+// validateQueryPassthrough checks if the provided passthrough settings are valid
+func validateQueryPassthrough(pt *QueryPassthrough) error {
+	if pt == nil {
+		return nil
+	}
+	if err := validatePassthroughPolicy(pt.Policy); err != nil {
+		return err
+	}
+	for name, policy := range pt.Params {
+		if err := validatePassthroughPolicy(policy); err != nil {
+			return fmt.Errorf("parameter %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// policyFor returns the conflict policy for a parameter, defaulting to keep
+func (pt *QueryPassthrough) policyFor(name string) string {
+	if policy := pt.Params[name]; policy != "" {
+		return policy
+	}
+	if pt.Policy != "" {
+		return pt.Policy
+	}
+	return passthroughKeep
+}
+
+// mergeQuery adds the incoming query parameters to the destination URL
+// according to the passthrough settings. It returns the new destination and
+// the parameters that were actually forwarded. The destination's own query is
+// kept byte for byte apart from parameters an override replaces, so signed
+// URLs and unusual encodings survive.
+//
+// Fragments need no handling here: browsers carry the original URL's fragment
+// over to a Location header that has none, and keep the destination's own.
+func mergeQuery(destination string, incoming url.Values, pt *QueryPassthrough) (string, url.Values) {
+	if pt == nil || !pt.Enabled || len(incoming) == 0 {
+		return destination, nil
+	}
+
+	base, rawQuery, fragment := splitQuery(destination)
+	existing, _ := url.ParseQuery(rawQuery)
+	replaced := url.Values{}
+	added := url.Values{}
+	forwarded := url.Values{}
+	for name, values := range incoming {
+		if _, exists := existing[name]; exists {
+			switch pt.policyFor(name) {
+			case passthroughKeep:
+				continue
+			case passthroughOverride:
+				replaced[name] = values
+			case passthroughAppend:
+				added[name] = values
+			}
+		} else {
+			added[name] = values
+		}
+		forwarded[name] = values
+	}
+
+	if len(forwarded) == 0 {
+		return destination, nil
+	}
+	if len(replaced) > 0 {
+		rawQuery = replaceQueryParams(rawQuery, replaced)
+	}
+	return joinQuery(base, appendQuery(rawQuery, added.Encode()), fragment), forwarded
+}
+
+// splitQuery splits a URL into the part before its query, its raw query and
+// its fragment, including the '#'
+func splitQuery(rawURL string) (base, rawQuery, fragment string) {
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		rawURL, fragment = rawURL[:i], rawURL[i:]
+	}
+	base, rawQuery, _ = strings.Cut(rawURL, "?")
+	return base, rawQuery, fragment
+}
+
+// joinQuery reassembles a URL taken apart by splitQuery
+func joinQuery(base, rawQuery, fragment string) string {
+	if rawQuery == "" {
+		return base + fragment
+	}
+	return base + "?" + rawQuery + fragment
+}
+
+// appendQuery adds encoded pairs to the end of a raw query
+func appendQuery(rawQuery, pairs string) string {
+	if rawQuery == "" || pairs == "" {
+		return rawQuery + pairs
+	}
+	return rawQuery + "&" + pairs
+}
+
+// replaceQueryParams swaps the values of the given parameters in a raw query,
+// writing the new values where each name first appears. All other pairs are
+// kept exactly as they were.
+func replaceQueryParams(rawQuery string, values url.Values) string {
+	pairs := strings.Split(rawQuery, "&")
+	kept := make([]string, 0, len(pairs))
+	written := make(map[string]bool)
+	for _, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if _, replace := values[name]; err != nil || !replace {
+			kept = append(kept, pair)
+			continue
+		}
+		if !written[name] {
+			kept = append(kept, url.Values{name: values[name]}.Encode())
+			written[name] = true
+		}
+	}
+	return strings.Join(kept, "&")
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMergeQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		destination   string
+		incoming      string
+		pt            *QueryPassthrough
+		want          string
+		wantForwarded string
+	}{
+		{
+			name:        "disabled",
+			destination: "https://example.com/?a=1",
+			incoming:    "b=2",
+			pt:          &QueryPassthrough{Enabled: false},
+			want:        "https://example.com/?a=1",
+		},
+		{
+			name:        "no settings",
+			destination: "https://example.com/?a=1",
+			incoming:    "b=2",
+			want:        "https://example.com/?a=1",
+		},
+		{
+			name:          "new parameter is added",
+			destination:   "https://example.com/path?a=1",
+			incoming:      "b=2",
+			pt:            &QueryPassthrough{Enabled: true},
+			want:          "https://example.com/path?a=1&b=2",
+			wantForwarded: "b=2",
+		},
+		{
+			name:        "conflict keeps destination by default",
+			destination: "https://example.com/?a=1",
+			incoming:    "a=2",
+			pt:          &QueryPassthrough{Enabled: true},
+			want:        "https://example.com/?a=1",
+		},
+		{
+			name:          "override policy",
+			destination:   "https://example.com/?a=1",
+			incoming:      "a=2",
+			pt:            &QueryPassthrough{Enabled: true, Policy: passthroughOverride},
+			want:          "https://example.com/?a=2",
+			wantForwarded: "a=2",
+		},
+		{
+			name:          "append policy",
+			destination:   "https://example.com/?a=1",
+			incoming:      "a=2",
+			pt:            &QueryPassthrough{Enabled: true, Policy: passthroughAppend},
+			want:          "https://example.com/?a=1&a=2",
+			wantForwarded: "a=2",
+		},
+		{
+			name:          "per-parameter policy beats the default",
+			destination:   "https://example.com/?a=1&b=1",
+			incoming:      "a=2&b=2",
+			pt:            &QueryPassthrough{Enabled: true, Policy: passthroughOverride, Params: map[string]string{"b": passthroughKeep}},
+			want:          "https://example.com/?a=2&b=1",
+			wantForwarded: "a=2",
+		},
+		{
+			name:          "per-parameter policy without a default",
+			destination:   "https://example.com/?a=1&b=1",
+			incoming:      "a=2&b=2",
+			pt:            &QueryPassthrough{Enabled: true, Params: map[string]string{"b": passthroughAppend}},
+			want:          "https://example.com/?a=1&b=1&b=2",
+			wantForwarded: "b=2",
+		},
+		{
+			name:          "untouched query is kept byte for byte",
+			destination:   "https://example.com/?sig=a%2Bb&z=1&a=1&flag&q=x+y",
+			incoming:      "new=1",
+			pt:            &QueryPassthrough{Enabled: true},
+			want:          "https://example.com/?sig=a%2Bb&z=1&a=1&flag&q=x+y&new=1",
+			wantForwarded: "new=1",
+		},
+		{
+			name:          "override only rewrites its own parameter",
+			destination:   "https://example.com/?z=%2B&a=1&flag&a=3&sig=x+y",
+			incoming:      "a=2",
+			pt:            &QueryPassthrough{Enabled: true, Policy: passthroughOverride},
+			want:          "https://example.com/?z=%2B&a=2&flag&sig=x+y",
+			wantForwarded: "a=2",
+		},
+		{
+			name:        "kept conflict leaves the destination untouched",
+			destination: "https://example.com/?b=%7e&a=1&",
+			incoming:    "a=2",
+			pt:          &QueryPassthrough{Enabled: true},
+			want:        "https://example.com/?b=%7e&a=1&",
+		},
+		{
+			name:          "fragment is kept",
+			destination:   "https://example.com/page#section",
+			incoming:      "ref=x",
+			pt:            &QueryPassthrough{Enabled: true},
+			want:          "https://example.com/page?ref=x#section",
+			wantForwarded: "ref=x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incoming, err := url.ParseQuery(tt.incoming)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, forwarded := mergeQuery(tt.destination, incoming, tt.pt)
+			if got != tt.want {
+				t.Errorf("destination = %q, want %q", got, tt.want)
+			}
+			if forwarded.Encode() != tt.wantForwarded {
+				t.Errorf("forwarded = %q, want %q", forwarded.Encode(), tt.wantForwarded)
+			}
+		})
+	}
+}
+
+func TestValidateQueryPassthrough(t *testing.T) {
+	tests := []struct {
+		name    string
+		pt      *QueryPassthrough
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"default policy", &QueryPassthrough{Enabled: true}, false},
+		{"all policies", &QueryPassthrough{Enabled: true, Policy: passthroughAppend, Params: map[string]string{"a": passthroughKeep, "b": passthroughOverride}}, false},
+		{"unknown policy", &QueryPassthrough{Enabled: true, Policy: "merge"}, true},
+		{"unknown parameter policy", &QueryPassthrough{Enabled: true, Params: map[string]string{"a": "drop"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateQueryPassthrough(tt.pt); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
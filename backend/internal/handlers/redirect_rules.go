@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"fmt"
+	"gochop/backend/internal/middleware"
+	"gochop/backend/internal/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxRedirectRules caps how many rules a single link may carry
+const maxRedirectRules = 20
+
+// RedirectRule routes matching visitors to TargetURL. Every condition that is
+// set must match; within a condition any listed value may match. Rules are
+// evaluated in order and the first match wins, falling back to long_url.
+type RedirectRule struct {
+	// Devices matches the parsed User-Agent: a device type (mobile, tablet,
+	// desktop, bot) or an operating system (ios, android, windows, macos, linux)
+	Devices []string `json:"devices,omitempty"`
+
+	// Countries matches the visitor's country by ISO code ("DE") or name ("Germany")
+	Countries []string `json:"countries,omitempty"`
+
+	// Languages matches Accept-Language by primary tag ("de") or full tag ("de-AT")
+	Languages []string `json:"languages,omitempty"`
+
+	TimeWindow *TimeWindow `json:"time_window,omitempty"`
+	TargetURL  string      `json:"target_url"`
+}
+
+// TimeWindow matches requests on the given weekdays and/or between Start and
+// End ("HH:MM", wrapping past midnight when End is before Start), evaluated in
+// Timezone (IANA name, default UTC)
+type TimeWindow struct {
+	Days     []string `json:"days,omitempty"` // mon, tue, wed, thu, fri, sat, sun
+	Start    string   `json:"start,omitempty"`
+	End      string   `json:"end,omitempty"`
+	Timezone string   `json:"timezone,omitempty"`
+}
+
+var ruleDevices = map[string]bool{
+	"mobile": true, "tablet": true, "desktop": true, "bot": true,
+	"ios": true, "android": true, "windows": true, "macos": true, "linux": true,
+}
+
+var ruleWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseClock parses "HH:MM" into minutes after midnight
+func parseClock(value string) (int, error) {
+	hours, minutes, ok := strings.Cut(value, ":")
+	h, errH := strconv.Atoi(hours)
+	m, errM := strconv.Atoi(minutes)
+	if !ok || errH != nil || errM != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return h*60 + m, nil
+}
+
+// validateRedirectRules checks if the provided rules are valid
+func validateRedirectRules(rules []RedirectRule) error {
+	if len(rules) > maxRedirectRules {
+		return fmt.Errorf("a link can have at most %d redirect rules", maxRedirectRules)
+	}
+	for i, rule := range rules {
+		if err := validateRedirectRule(rule); err != nil {
+			return fmt.Errorf("rule %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validateRedirectRule checks a single rule
+func validateRedirectRule(rule RedirectRule) error {
+	if err := validateURL(rule.TargetURL); err != nil {
+		return err
+	}
+	if len(rule.Devices) == 0 && len(rule.Countries) == 0 && len(rule.Languages) == 0 && rule.TimeWindow == nil {
+		return fmt.Errorf("at least one condition is required")
+	}
+	for _, device := range rule.Devices {
+		if !ruleDevices[strings.ToLower(device)] {
+			return fmt.Errorf("unknown device %q", device)
+		}
+	}
+	if w := rule.TimeWindow; w != nil {
+		for _, day := range w.Days {
+			if _, ok := ruleWeekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("unknown day %q", day)
+			}
+		}
+		if (w.Start == "") != (w.End == "") {
+			return fmt.Errorf("time window needs both start and end")
+		}
+		if w.Start != "" {
+			if _, err := parseClock(w.Start); err != nil {
+				return err
+			}
+			if _, err := parseClock(w.End); err != nil {
+				return err
+			}
+		}
+		if w.Timezone != "" {
+			if _, err := time.LoadLocation(w.Timezone); err != nil {
+				return fmt.Errorf("unknown timezone %q", w.Timezone)
+			}
+		}
+	}
+	return nil
+}
+
+// ruleVisitor holds what rules can match on. The location is looked up lazily
+// because it costs a call to the geo service.
+type ruleVisitor struct {
+	c         *fiber.Ctx
+	device    services.DeviceInfo
+	languages []string
+	now       time.Time
+	geo       *services.GeoLocation
+}
+
+// newRuleVisitor parses the request details rules match on
+func newRuleVisitor(c *fiber.Ctx) *ruleVisitor {
+	return &ruleVisitor{
+		c:         c,
+		device:    services.ParseUserAgent(c.Get(fiber.HeaderUserAgent)),
+		languages: parseAcceptLanguage(c.Get(fiber.HeaderAcceptLanguage)),
+		now:       time.Now(),
+	}
+}
+
+// location returns the visitor's geo location. It is stored in the "geo" local
+// so AnalyticsMiddleware does not look it up a second time.
+func (v *ruleVisitor) location() *services.GeoLocation {
+	if v.geo != nil {
+		return v.geo
+	}
+	if geo, ok := v.c.Locals("geo").(*services.GeoLocation); ok {
+		v.geo = geo
+		return geo
+	}
+	clientIP := middleware.GetClientIP(v.c)
+	geo, err := services.GetLocationFromIP(clientIP)
+	if err != nil {
+		geo = services.GetLocationFromIPFallback(clientIP)
+	}
+	v.c.Locals("geo", geo)
+	v.geo = geo
+	return geo
+}
+
+// parseAcceptLanguage returns the lower-cased language tags the visitor accepts
+func parseAcceptLanguage(header string) []string {
+	var languages []string
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" || strings.TrimSpace(params) == "q=0" {
+			continue
+		}
+		languages = append(languages, strings.ToLower(tag))
+	}
+	return languages
+}
+
+// matches reports whether every condition set on the rule matches the visitor
+func (rule *RedirectRule) matches(v *ruleVisitor) bool {
+	if len(rule.Devices) > 0 && !rule.matchesDevice(v.device) {
+		return false
+	}
+	if len(rule.Languages) > 0 && !rule.matchesLanguage(v.languages) {
+		return false
+	}
+	if rule.TimeWindow != nil && !rule.TimeWindow.contains(v.now) {
+		return false
+	}
+	// Checked last since it may call the geo service
+	if len(rule.Countries) > 0 && !rule.matchesCountry(v.location()) {
+		return false
+	}
+	return true
+}
+
+// matchesDevice reports whether the visitor's device type or OS is listed
+func (rule *RedirectRule) matchesDevice(device services.DeviceInfo) bool {
+	for _, d := range rule.Devices {
+		d = strings.ToLower(d)
+		if d == device.DeviceType || d == device.OS {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesLanguage reports whether the visitor accepts one of the listed languages
+func (rule *RedirectRule) matchesLanguage(languages []string) bool {
+	for _, want := range rule.Languages {
+		want = strings.ToLower(want)
+		for _, lang := range languages {
+			primary, _, _ := strings.Cut(lang, "-")
+			if lang == want || primary == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesCountry reports whether the visitor's country is listed
+func (rule *RedirectRule) matchesCountry(geo *services.GeoLocation) bool {
+	for _, country := range rule.Countries {
+		if strings.EqualFold(country, geo.CountryCode) || strings.EqualFold(country, geo.Country) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether the time falls inside the window
+func (w *TimeWindow) contains(t time.Time) bool {
+	// An empty timezone loads as UTC
+	if loc, err := time.LoadLocation(w.Timezone); err == nil {
+		t = t.In(loc)
+	}
+
+	if len(w.Days) > 0 {
+		found := false
+		for _, day := range w.Days {
+			if ruleWeekdays[strings.ToLower(day)] == t.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if w.Start == "" {
+		return true
+	}
+	start, errStart := parseClock(w.Start)
+	end, errEnd := parseClock(w.End)
+	if errStart != nil || errEnd != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end // window wraps past midnight
+}
+
+// firstMatchingRule returns the first rule matching the visitor, or nil
+func firstMatchingRule(rules []RedirectRule, v *ruleVisitor) *RedirectRule {
+	for i := range rules {
+		if rules[i].matches(v) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// resolveDestination returns the target of the first matching rule. When no
+// rule matches it falls back to the link's A/B test, returning the name of the
+// variant served, or to long_url.
+//
+// Like long_url, rule and variant targets were checked against the URL policy
+// when they were saved and are not re-checked here: a domain blocked later is
+// still served until the link is edited or deactivated.
+func resolveDestination(c *fiber.Ctx, shortCode string, link *cachedLink) (string, string) {
+	if len(link.Rules) > 0 {
+		if rule := firstMatchingRule(link.Rules, newRuleVisitor(c)); rule != nil {
+			return rule.TargetURL, ""
+		}
+	}
+	if link.ABTest != nil {
+		variant := link.ABTest.pickVariant(c, shortCode)
+		return variant.URL, variant.Name
+	}
+	return link.LongURL, ""
+}
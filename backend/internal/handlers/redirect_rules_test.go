@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"gochop/backend/internal/services"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFirstMatchingRuleOrder(t *testing.T) {
+	mobile := RedirectRule{Devices: []string{"mobile"}, TargetURL: "https://m.example.com"}
+	ios := RedirectRule{Devices: []string{"ios"}, TargetURL: "https://ios.example.com"}
+	german := RedirectRule{Languages: []string{"de"}, TargetURL: "https://de.example.com"}
+	germany := RedirectRule{Countries: []string{"DE"}, TargetURL: "https://germany.example.com"}
+	iosInGerman := RedirectRule{Devices: []string{"ios"}, Languages: []string{"de"}, TargetURL: "https://ios-de.example.com"}
+
+	iphone := services.DeviceInfo{DeviceType: "mobile", OS: "ios"}
+	desktop := services.DeviceInfo{DeviceType: "desktop", OS: "windows"}
+	berlin := &services.GeoLocation{Country: "Germany", CountryCode: "DE"}
+	paris := &services.GeoLocation{Country: "France", CountryCode: "FR"}
+
+	tests := []struct {
+		name    string
+		rules   []RedirectRule
+		visitor *ruleVisitor
+		want    string
+	}{
+		{"first match wins", []RedirectRule{mobile, ios}, &ruleVisitor{device: iphone}, mobile.TargetURL},
+		{"order decides", []RedirectRule{ios, mobile}, &ruleVisitor{device: iphone}, ios.TargetURL},
+		{"skips non-matching", []RedirectRule{mobile, german}, &ruleVisitor{device: desktop, languages: []string{"de-at"}}, german.TargetURL},
+		{"all conditions must match", []RedirectRule{iosInGerman, ios}, &ruleVisitor{device: iphone, languages: []string{"en"}}, ios.TargetURL},
+		{"country by code", []RedirectRule{germany}, &ruleVisitor{device: desktop, geo: berlin}, germany.TargetURL},
+		{"country by name", []RedirectRule{{Countries: []string{"germany"}, TargetURL: "https://name.example.com"}}, &ruleVisitor{geo: berlin}, "https://name.example.com"},
+		{"no match", []RedirectRule{mobile, germany}, &ruleVisitor{device: desktop, geo: paris}, ""},
+		{"no rules", nil, &ruleVisitor{device: iphone}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if rule := firstMatchingRule(tt.rules, tt.visitor); rule != nil {
+				got = rule.TargetURL
+			}
+			if got != tt.want {
+				t.Errorf("matched %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstMatchingRuleSkipsGeoLookup(t *testing.T) {
+	// A visitor without a request context would panic on a geo lookup, so
+	// this fails if a country condition is checked before a failing one
+	rules := []RedirectRule{
+		{Devices: []string{"mobile"}, Countries: []string{"DE"}, TargetURL: "https://a.example.com"},
+		{Devices: []string{"desktop"}, TargetURL: "https://b.example.com"},
+	}
+	v := &ruleVisitor{device: services.DeviceInfo{DeviceType: "desktop"}}
+	if rule := firstMatchingRule(rules, v); rule == nil || rule.TargetURL != "https://b.example.com" {
+		t.Errorf("matched %v, want the desktop rule", rule)
+	}
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	// 2024-01-01 is a Monday
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name   string
+		window TimeWindow
+		t      time.Time
+		want   bool
+	}{
+		{"inside", TimeWindow{Start: "09:00", End: "17:00"}, at(12, 0), true},
+		{"start is inclusive", TimeWindow{Start: "09:00", End: "17:00"}, at(9, 0), true},
+		{"end is exclusive", TimeWindow{Start: "09:00", End: "17:00"}, at(17, 0), false},
+		{"wraps past midnight, late", TimeWindow{Start: "22:00", End: "06:00"}, at(23, 30), true},
+		{"wraps past midnight, early", TimeWindow{Start: "22:00", End: "06:00"}, at(5, 59), true},
+		{"wraps past midnight, outside", TimeWindow{Start: "22:00", End: "06:00"}, at(12, 0), false},
+		{"matching day", TimeWindow{Days: []string{"mon"}}, at(12, 0), true},
+		{"other day", TimeWindow{Days: []string{"sat", "sun"}}, at(12, 0), false},
+		{"day and hours", TimeWindow{Days: []string{"Mon"}, Start: "13:00", End: "14:00"}, at(12, 0), false},
+		{"timezone", TimeWindow{Start: "09:00", End: "10:00", Timezone: "Europe/Berlin"}, at(8, 30), true},
+		{"timezone shifts the day", TimeWindow{Days: []string{"sun"}, Timezone: "America/New_York"}, at(2, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.contains(tt.t); got != tt.want {
+				t.Errorf("contains = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", nil},
+		{"de-AT,de;q=0.9,en;q=0.8", []string{"de-at", "de", "en"}},
+		{"fr, *;q=0.5", []string{"fr"}},
+		{"en;q=0, es", []string{"es"}},
+	}
+	for _, tt := range tests {
+		if got := parseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAcceptLanguage(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TagRequest defines the body for creating or renaming a tag
+type TagRequest struct {
+	Name string `json:"name"`
+}
+
+// parseTagRequest reads and normalizes the tag name from the request body
+func parseTagRequest(c *fiber.Ctx) (string, error) {
+	req := new(TagRequest)
+	if err := c.BodyParser(req); err != nil {
+		return "", errors.New("Cannot parse JSON")
+	}
+	return services.NormalizeTagName(req.Name)
+}
+
+// tagError responds with the HTTP error for a tag service error
+func tagError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrTagExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrTagNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Tag not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not update tag",
+	})
+}
+
+// GetTags lists the current user's tags with aggregate link and click counts
+func GetTags(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	tags, err := tagService.ListTags(db.Ctx, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve tags",
+		})
+	}
+
+	return c.JSON(tags)
+}
+
+// CreateTag creates a tag for the current user
+func CreateTag(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	name, err := parseTagRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	tag, err := tagService.CreateTag(db.Ctx, userID, name)
+	if err != nil {
+		return tagError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(tag)
+}
+
+// RenameTag renames one of the current user's tags
+func RenameTag(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	tagID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tag ID",
+		})
+	}
+
+	name, err := parseTagRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	tag, err := tagService.RenameTag(db.Ctx, userID, tagID, name)
+	if err != nil {
+		return tagError(c, err)
+	}
+
+	return c.JSON(tag)
+}
+
+// DeleteTag deletes one of the current user's tags and removes it from their links
+func DeleteTag(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	tagID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tag ID",
+		})
+	}
+
+	if err := tagService.DeleteTag(db.Ctx, userID, tagID); err != nil {
+		return tagError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Tag deleted successfully",
+	})
+}
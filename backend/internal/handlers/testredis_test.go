@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"gochop/backend/internal/db"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// useTestRedis points db.RDB at an in-memory Redis for the duration of the test
+func useTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	server := miniredis.RunT(t)
+	previous := db.RDB
+	db.RDB = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		db.RDB.Close()
+		db.RDB = previous
+	})
+	return server
+}
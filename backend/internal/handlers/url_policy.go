@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"gochop/backend/internal/db"
+	"gochop/backend/internal/services"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var blocklistService = services.NewBlocklistService()
+
+// knownShorteners are URL shortener domains. Links to them are rejected: a
+// chain of shorteners hides the real destination from visitors and from our
+// own policy checks.
+var knownShorteners = map[string]bool{
+	"bit.ly": true, "bitly.com": true, "tinyurl.com": true, "t.co": true, "goo.gl": true,
+	"ow.ly": true, "is.gd": true, "v.gd": true, "buff.ly": true, "rebrand.ly": true,
+	"cutt.ly": true, "shorturl.at": true, "tiny.cc": true, "rb.gy": true, "s.id": true,
+	"bl.ink": true, "t.ly": true, "lnkd.in": true, "shorte.st": true, "adf.ly": true,
+}
+
+// allowContactSchemes reports whether mailto: and tel: links may be created
+func allowContactSchemes() bool {
+	return os.Getenv("ALLOW_MAILTO_TEL_LINKS") == "true"
+}
+
+// isSelfHost reports whether a URL points at this service's base host. Only
+// hostnames are compared: a different scheme or port on the same host still
+// reaches this service.
+func isSelfHost(u *url.URL) bool {
+	base, err := url.Parse(getBaseURL())
+	if err != nil || base.Hostname() == "" {
+		return false
+	}
+	return services.NormalizeDomain(u.Hostname()) == services.NormalizeDomain(base.Hostname())
+}
+
+// isShortener reports whether the host belongs to a known URL shortener
+func isShortener(host string) bool {
+	host = services.NormalizeDomain(host)
+	for host != "" {
+		if knownShorteners[host] {
+			return true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return false
+}
+
+// checkURLPolicy applies the destination safety policy to a web URL: it may
+// not point back at this service, at another URL shortener, or at a host on
+// the admin-managed blocklist
+func checkURLPolicy(u *url.URL) error {
+	if isSelfHost(u) {
+		return fmt.Errorf("URL cannot point to this link shortener")
+	}
+	if domainService.IsCustomHost(db.Ctx, u.Hostname()) {
+		return fmt.Errorf("URL cannot point to this link shortener")
+	}
+
+	if isShortener(u.Hostname()) {
+		return fmt.Errorf("URL cannot point to another link shortener")
+	}
+
+	blocked, err := blocklistService.IsBlocked(db.Ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("could not check the URL against the blocklist")
+	}
+	if blocked {
+		return fmt.Errorf("URL points to a blocked domain")
+	}
+	return nil
+}
@@ -8,6 +8,30 @@ import (
 )
 
 var userService = services.NewUserService()
+var utmService = services.NewUTMService()
+var tagService = services.NewTagService()
+
+// getUserStats returns a user's statistics with the links in them addressed
+// by their public short code and short URL rather than their storage key
+func getUserStats(userID string) (map[string]interface{}, error) {
+	stats, err := userService.GetUserStats(db.Ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if expiring, ok := stats["expiring_links"].([]services.ExpiringLink); ok {
+		for i := range expiring {
+			expiring[i].ShortURL = shortURLFor(expiring[i].ShortCode)
+			expiring[i].ShortCode = publicCode(expiring[i].ShortCode)
+		}
+	}
+	if mostClicked, ok := stats["most_clicked_link"].(map[string]interface{}); ok {
+		if key, ok := mostClicked["short_code"].(string); ok {
+			mostClicked["short_url"] = shortURLFor(key)
+			mostClicked["short_code"] = publicCode(key)
+		}
+	}
+	return stats, nil
+}
 
 // GetUserProfile retrieves the current user's profile information with full details
 func GetUserProfile(c *fiber.Ctx) error {
@@ -28,7 +52,7 @@ func GetUserProfile(c *fiber.Ctx) error {
 	}
 
 	// Get user statistics
-	stats, err := userService.GetUserStats(db.Ctx, userID)
+	stats, err := getUserStats(userID)
 	if err != nil {
 		// If stats fail, continue without them
 		stats = map[string]interface{}{}
@@ -83,7 +107,7 @@ func GetUserStats(c *fiber.Ctx) error {
 	}
 
 	// Get user statistics
-	stats, err := userService.GetUserStats(db.Ctx, userID)
+	stats, err := getUserStats(userID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to retrieve statistics",
@@ -93,6 +117,69 @@ func GetUserStats(c *fiber.Ctx) error {
 	return c.JSON(stats)
 }
 
+// GetUTMTemplate returns the current user's UTM template
+func GetUTMTemplate(c *fiber.Ctx) error {
+	// Get user ID from context (set by NextAuth middleware)
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	template, err := utmService.GetTemplate(db.Ctx, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve UTM template",
+		})
+	}
+	if template == nil {
+		template = &services.UTMParams{}
+	}
+
+	return c.JSON(template)
+}
+
+// UpdateUTMTemplate replaces the current user's UTM template. The tags apply
+// at redirect time to all of the user's links that do not set them themselves.
+func UpdateUTMTemplate(c *fiber.Ctx) error {
+	// Get user ID from context (set by NextAuth middleware)
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User authentication required",
+		})
+	}
+
+	// Parse request body
+	var template services.UTMParams
+	if err := c.BodyParser(&template); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := template.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := utmService.SetTemplate(db.Ctx, userID, &template); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update UTM template",
+		})
+	}
+
+	// Cached link records embed the template, so drop them
+	evictUserLinks(userID)
+
+	return c.JSON(fiber.Map{
+		"message":      "UTM template updated successfully",
+		"utm_template": template,
+	})
+}
+
 // CreateOrUpdateUser creates or updates a user (called by NextAuth webhook or during authentication)
 func CreateOrUpdateUser(c *fiber.Ctx) error {
 	// Parse request body
@@ -155,7 +242,7 @@ func GetUserByID(c *fiber.Ctx) error {
 	}
 
 	// Get user statistics
-	stats, err := userService.GetUserStats(db.Ctx, userID)
+	stats, err := getUserStats(userID)
 	if err != nil {
 		stats = map[string]interface{}{}
 	}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"gochop/backend/internal/services"
+	"net/url"
+)
+
+// applyUTM appends UTM tags to a destination URL. Tags the destination already
+// carries are left alone, so the stored long_url stays authoritative, and the
+// rest of its query is kept byte for byte. mailto: and tel: destinations are
+// not tagged.
+func applyUTM(destination string, utm *services.UTMParams) string {
+	if utm.IsEmpty() {
+		return destination
+	}
+	u, err := url.Parse(destination)
+	if err != nil || u.Opaque != "" {
+		return destination
+	}
+
+	base, rawQuery, fragment := splitQuery(destination)
+	existing, _ := url.ParseQuery(rawQuery)
+	blank := url.Values{}
+	added := url.Values{}
+	for name, value := range utm.Values() {
+		if existing.Get(name) != "" {
+			continue
+		}
+		if _, ok := existing[name]; ok {
+			blank.Set(name, value)
+		} else {
+			added.Set(name, value)
+		}
+	}
+	if len(blank) == 0 && len(added) == 0 {
+		return destination
+	}
+	if len(blank) > 0 {
+		rawQuery = replaceQueryParams(rawQuery, blank)
+	}
+	return joinQuery(base, appendQuery(rawQuery, added.Encode()), fragment)
+}
+
+// utmCampaign returns the utm_campaign of the URL a visitor is sent to
+func utmCampaign(destination string) string {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("utm_campaign")
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"gochop/backend/internal/services"
+	"testing"
+)
+
+func TestApplyUTM(t *testing.T) {
+	utm := &services.UTMParams{Source: "news", Campaign: "spring"}
+	tests := []struct {
+		name        string
+		destination string
+		want        string
+	}{
+		{"no query", "https://example.com/", "https://example.com/?utm_campaign=spring&utm_source=news"},
+		{"existing query is kept byte for byte", "https://example.com/?sig=a%2Bb&z=1&flag", "https://example.com/?sig=a%2Bb&z=1&flag&utm_campaign=spring&utm_source=news"},
+		{"destination tags win", "https://example.com/?utm_source=site&b=%7e", "https://example.com/?utm_source=site&b=%7e&utm_campaign=spring"},
+		{"blank tag is filled in place", "https://example.com/?utm_campaign=&b=1", "https://example.com/?utm_campaign=spring&b=1&utm_source=news"},
+		{"fully tagged", "https://example.com/?utm_source=a&utm_campaign=b", "https://example.com/?utm_source=a&utm_campaign=b"},
+		{"fragment", "https://example.com/page#top", "https://example.com/page?utm_campaign=spring&utm_source=news#top"},
+		{"mailto", "mailto:hi@example.com", "mailto:hi@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyUTM(tt.destination, utm); got != tt.want {
+				t.Errorf("applyUTM = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"gochop/backend/internal/db"
 	"gochop/backend/internal/services"
 	"net"
+	"os"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,13 +19,22 @@ type AnalyticsData struct {
 	Country   string
 	Region    string
 	City      string
+
+	// QueryParams holds the query parameters forwarded to the destination
+	QueryParams string
+
+	// UTMCampaign is the utm_campaign of the destination the visitor was sent to
+	UTMCampaign string
+
+	// Variant is the name of the A/B test variant the visitor was sent to
+	Variant string
 }
 
 // LogAnalytics logs analytics data asynchronously to avoid blocking the request
 func LogAnalytics(data AnalyticsData) {
 	go func() {
-		insertSQL := `INSERT INTO analytics (short_code, ip_address, user_agent, referrer, country, region, city) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-		_, err := db.DB.Exec(db.Ctx, insertSQL, data.ShortCode, data.IPAddress, data.UserAgent, data.Referrer, data.Country, data.Region, data.City)
+		insertSQL := `INSERT INTO analytics (short_code, ip_address, user_agent, referrer, country, region, city, query_params, utm_campaign, variant) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''))`
+		_, err := db.DB.Exec(db.Ctx, insertSQL, data.ShortCode, data.IPAddress, data.UserAgent, data.Referrer, data.Country, data.Region, data.City, data.QueryParams, data.UTMCampaign, data.Variant)
 		if err != nil {
 			// Log error but don't fail the request
 			// In a production environment, you'd want proper logging here
@@ -56,6 +66,45 @@ func GetClientIP(c *fiber.Ctx) string {
 	return c.IP()
 }
 
+// GetTrustedClientIP returns the client IP for security decisions such as
+// throttling, which must not be spoofable. X-Forwarded-For is only believed
+// when the connection comes from a proxy listed in TRUSTED_PROXIES, and then
+// the right-most address that is not such a proxy is used, since the entries
+// before it are supplied by the client.
+func GetTrustedClientIP(c *fiber.Ctx) string {
+	peer := c.IP()
+	trusted := getTrustedProxies()
+	if !isIPInList(peer, trusted) {
+		return peer
+	}
+
+	forwarded := strings.Split(c.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(ip) == nil {
+			break
+		}
+		if !isIPInList(ip, trusted) {
+			return ip
+		}
+	}
+	return peer
+}
+
+// getTrustedProxies returns the IPs and CIDR ranges of the reverse proxies in
+// front of the server
+func getTrustedProxies() []string {
+	proxies := os.Getenv("TRUSTED_PROXIES")
+	if proxies == "" {
+		return nil
+	}
+	ips := strings.Split(proxies, ",")
+	for i, ip := range ips {
+		ips[i] = strings.TrimSpace(ip)
+	}
+	return ips
+}
+
 // AnalyticsMiddleware middleware for logging analytics data
 func AnalyticsMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -71,14 +120,40 @@ func AnalyticsMiddleware() fiber.Handler {
 			return c.Next()
 		}
 
-		// Get geographic data from IP
+		// "/:shortCode+" is the destination preview page, never a click
+		if strings.HasSuffix(shortCode, "+") {
+			return c.Next()
+		}
+
+		// Let the handler run first: it may mark the request as not being a
+		// click (e.g. a password prompt) by setting the "skipAnalytics" local
+		handlerErr := c.Next()
+		if skip, _ := c.Locals("skipAnalytics").(bool); skip {
+			return handlerErr
+		}
+
+		// Links on custom domains are stored under a key that includes the host
+		if key, ok := c.Locals("linkKey").(string); ok {
+			shortCode = key
+		}
+
+		// Get geographic data from IP, unless the handler already looked it up
 		clientIP := GetClientIP(c)
-		geoData, err := services.GetLocationFromIP(clientIP)
-		if err != nil {
-			// Use fallback if geo service fails
-			geoData = services.GetLocationFromIPFallback(clientIP)
+		geoData, ok := c.Locals("geo").(*services.GeoLocation)
+		if !ok {
+			var err error
+			geoData, err = services.GetLocationFromIP(clientIP)
+			if err != nil {
+				// Use fallback if geo service fails
+				geoData = services.GetLocationFromIPFallback(clientIP)
+			}
 		}
 
+		// Parameters the handler forwarded to the destination, if any
+		forwardedParams, _ := c.Locals("forwardedParams").(string)
+		utmCampaign, _ := c.Locals("utmCampaign").(string)
+		variant, _ := c.Locals("variant").(string)
+
 		// Log analytics data
 		LogAnalytics(AnalyticsData{
 			ShortCode: shortCode,
@@ -88,8 +163,12 @@ func AnalyticsMiddleware() fiber.Handler {
 			Country:   geoData.Country,
 			Region:    geoData.Region,
 			City:      geoData.City,
+
+			QueryParams: forwardedParams,
+			UTMCampaign: utmCampaign,
+			Variant:     variant,
 		})
 
-		return c.Next()
+		return handlerErr
 	}
 } 
\ No newline at end of file
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func TestGetTrustedClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trusted   string
+		peer      string
+		forwarded string
+		want      string
+	}{
+		{"no proxies ignores the header", "", "203.0.113.9", "198.51.100.1", "203.0.113.9"},
+		{"untrusted peer ignores the header", "10.0.0.0/8", "203.0.113.9", "198.51.100.1", "203.0.113.9"},
+		{"trusted proxy", "10.0.0.0/8", "10.1.2.3", "198.51.100.1", "198.51.100.1"},
+		{"spoofed entries are skipped", "10.0.0.0/8", "10.1.2.3", "1.1.1.1, 2.2.2.2, 198.51.100.1", "198.51.100.1"},
+		{"proxy chain", "10.0.0.0/8, 192.0.2.7", "10.1.2.3", "1.1.1.1, 198.51.100.1, 192.0.2.7", "198.51.100.1"},
+		{"missing header", "10.0.0.0/8", "10.1.2.3", "", "10.1.2.3"},
+		{"invalid entry", "10.0.0.0/8", "10.1.2.3", "198.51.100.1, bogus", "10.1.2.3"},
+	}
+	app := fiber.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.trusted)
+
+			var req fasthttp.Request
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			var reqCtx fasthttp.RequestCtx
+			reqCtx.Init(&req, &net.TCPAddr{IP: net.ParseIP(tt.peer)}, nil)
+			c := app.AcquireCtx(&reqCtx)
+			defer app.ReleaseCtx(c)
+
+			if got := GetTrustedClientIP(c); got != tt.want {
+				t.Errorf("GetTrustedClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gochop/backend/internal/db"
+	"strings"
+	"sync"
+	"time"
+)
+
+// blocklistRefreshInterval bounds how long another instance's changes to the
+// blocklist can take to reach this instance's in-memory copy
+const blocklistRefreshInterval = time.Minute
+
+// ErrInvalidDomain is returned when adding something that is not a domain or host
+var ErrInvalidDomain = errors.New("invalid domain")
+
+// BlockedDomain is a domain or host that links may not point to
+type BlockedDomain struct {
+	Domain    string    `json:"domain"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlocklistService manages the destination blocklist. The list is kept in
+// memory and reloaded from PostgreSQL periodically and after every change.
+type BlocklistService struct {
+	mu       sync.RWMutex
+	domains  map[string]bool
+	loadedAt time.Time
+}
+
+// NewBlocklistService creates a new blocklist service
+func NewBlocklistService() *BlocklistService {
+	return &BlocklistService{}
+}
+
+// NormalizeDomain lower-cases a domain and strips a trailing dot, so
+// "Example.COM." and "example.com" are the same entry
+func NormalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// reload replaces the in-memory copy with the blocklist from PostgreSQL
+func (s *BlocklistService) reload(ctx context.Context) error {
+	rows, err := db.DB.Query(ctx, "SELECT domain FROM blocked_domains")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	domains := make(map[string]bool)
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return err
+		}
+		domains[domain] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.domains = domains
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// IsBlocked reports whether the host or any of its parent domains is on the
+// blocklist, e.g. "a.b.example.com" is blocked by "example.com"
+func (s *BlocklistService) IsBlocked(ctx context.Context, host string) (bool, error) {
+	s.mu.RLock()
+	stale := s.domains == nil || time.Since(s.loadedAt) > blocklistRefreshInterval
+	s.mu.RUnlock()
+	if stale {
+		if err := s.reload(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	host = NormalizeDomain(host)
+	for host != "" {
+		if s.domains[host] {
+			return true, nil
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return false, nil
+}
+
+// List returns every blocked domain, newest first
+func (s *BlocklistService) List(ctx context.Context) ([]BlockedDomain, error) {
+	query := `
+		SELECT domain, COALESCE(reason, ''), COALESCE(created_by::text, ''), created_at
+		FROM blocked_domains
+		ORDER BY created_at DESC
+	`
+	rows, err := db.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []BlockedDomain{}
+	for rows.Next() {
+		var domain BlockedDomain
+		if err := rows.Scan(&domain.Domain, &domain.Reason, &domain.CreatedBy, &domain.CreatedAt); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+// Add puts a domain on the blocklist. Adding a domain that is already blocked
+// updates its reason.
+func (s *BlocklistService) Add(ctx context.Context, domain, reason, createdBy string) (*BlockedDomain, error) {
+	domain = NormalizeDomain(domain)
+	if domain == "" || len(domain) > 253 || strings.ContainsAny(domain, "/:@ ") {
+		return nil, ErrInvalidDomain
+	}
+
+	query := `
+		INSERT INTO blocked_domains (domain, reason, created_by)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, '')::uuid)
+		ON CONFLICT (domain) DO UPDATE SET reason = EXCLUDED.reason
+		RETURNING domain, COALESCE(reason, ''), COALESCE(created_by::text, ''), created_at
+	`
+	var blocked BlockedDomain
+	err := db.DB.QueryRow(ctx, query, domain, reason, createdBy).Scan(&blocked.Domain, &blocked.Reason, &blocked.CreatedBy, &blocked.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &blocked, s.reload(ctx)
+}
+
+// Remove takes a domain off the blocklist, reporting whether it was listed
+func (s *BlocklistService) Remove(ctx context.Context, domain string) (bool, error) {
+	tag, err := db.DB.Exec(ctx, "DELETE FROM blocked_domains WHERE domain = $1", NormalizeDomain(domain))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, s.reload(ctx)
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"gochop/backend/internal/db"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	codePoolKey         = "shortcode:pool"
+	codePoolLockKey     = "shortcode:pool:refill_lock"
+	codePoolInterval    = 10 * time.Second
+	codePoolBatchSize   = 500
+	defaultCodePoolSize = 1000
+
+	// Reservations this old whose code is not waiting in the pool were taken
+	// by a request that never finished, e.g. because the process died
+	staleReservationAge = 24 * time.Hour
+	sweepInterval       = time.Hour
+)
+
+// ErrCodePoolEmpty is returned by Take when no reserved code is available
+var ErrCodePoolEmpty = errors.New("short code pool is empty")
+
+// reserveCodesSQL reserves the candidates that are neither used by a link nor
+// already reserved, returning the ones it reserved
+const reserveCodesSQL = `
+	INSERT INTO reserved_codes (code)
+	SELECT c.code FROM unnest($1::text[]) AS c(code)
+	WHERE NOT EXISTS (SELECT 1 FROM links l WHERE l.short_code = c.code)
+	ON CONFLICT (code) DO NOTHING
+	RETURNING code
+`
+
+// sweepReservationsSQL drops the reservations older than $1 seconds, except
+// those of the codes in $2
+const sweepReservationsSQL = `
+	DELETE FROM reserved_codes
+	WHERE reserved_at < NOW() - make_interval(secs => $1) AND NOT (code = ANY($2))
+`
+
+// releaseLockScript deletes a lock only if it still holds the caller's token,
+// so an instance whose lock expired cannot release another instance's lock
+var releaseLockScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0
+`)
+
+// newLockToken returns a random value identifying one holder of a lock
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CodePool hands out short codes for the default domain that were generated
+// in advance and reserved in Postgres, so creating a link does not have to
+// search for a free code. Codes are kept in a Redis list, which makes taking
+// one atomic across instances.
+type CodePool struct {
+	Codes *ShortCodeService
+
+	// Size is how many codes are kept ready; 0 disables the pool
+	Size int
+
+	// reserve reserves the free candidates; reserveCodes outside tests
+	reserve func(ctx context.Context, candidates []string) ([]string, error)
+
+	wake    chan struct{}
+	sweptAt time.Time
+}
+
+// NewCodePool creates a CodePool drawing from the given generator, holding
+// SHORT_CODE_POOL_SIZE codes (default 1000)
+func NewCodePool(codes *ShortCodeService) *CodePool {
+	p := &CodePool{
+		Codes:   codes,
+		Size:    defaultCodePoolSize,
+		reserve: reserveCodes,
+		wake:    make(chan struct{}, 1),
+	}
+	if size, err := strconv.Atoi(os.Getenv("SHORT_CODE_POOL_SIZE")); err == nil && size >= 0 {
+		p.Size = size
+	}
+	// Hash codes depend on the destination, so they cannot be made in advance
+	if _, ok := codes.Generator.(HashGenerator); ok {
+		p.Size = 0
+	}
+	return p
+}
+
+// Take removes a code from the pool. The caller owns the code: it is not
+// handed out again, and inserting it releases the reservation. A caller that
+// does not use the code must delete the reservation itself.
+func (p *CodePool) Take(ctx context.Context) (string, error) {
+	if p.Size == 0 {
+		return "", ErrCodePoolEmpty
+	}
+	code, err := db.RDB.LPop(ctx, codePoolKey).Result()
+	if err == redis.Nil {
+		p.triggerRefill()
+		return "", ErrCodePoolEmpty
+	}
+	return code, err
+}
+
+// triggerRefill wakes the refiller without waiting for its next round
+func (p *CodePool) triggerRefill() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run keeps the pool topped up until the context is cancelled, checking every
+// codePoolInterval or as soon as it runs dry. Only one instance refills at a
+// time, coordinated through Redis.
+func (p *CodePool) Run(ctx context.Context) {
+	if p.Size == 0 {
+		return
+	}
+	ticker := time.NewTicker(codePoolInterval)
+	defer ticker.Stop()
+	for {
+		if err := p.refillLocked(ctx); err != nil {
+			log.Printf("Short code pool: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.wake:
+		}
+	}
+}
+
+// refillLocked refills the pool if no other instance is doing so
+func (p *CodePool) refillLocked(ctx context.Context) error {
+	token, err := newLockToken()
+	if err != nil {
+		return err
+	}
+	acquired, err := db.RDB.SetNX(ctx, codePoolLockKey, token, codePoolInterval).Result()
+	if err != nil || !acquired {
+		return err
+	}
+	defer releaseLockScript.Run(ctx, db.RDB, []string{codePoolLockKey}, token)
+	if time.Since(p.sweptAt) >= sweepInterval {
+		if err := p.sweep(ctx); err != nil {
+			log.Printf("Short code pool: could not sweep stale reservations: %v", err)
+		} else {
+			p.sweptAt = time.Now()
+		}
+	}
+	return p.Refill(ctx)
+}
+
+// sweep drops the reservations of codes that were taken from the pool long
+// ago but never used or released. Codes still waiting in the pool keep
+// theirs, however old. Must be called with the refill lock held.
+func (p *CodePool) sweep(ctx context.Context) error {
+	pooled, err := db.RDB.LRange(ctx, codePoolKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	if pooled == nil {
+		pooled = []string{}
+	}
+	_, err = db.DB.Exec(ctx, sweepReservationsSQL, staleReservationAge.Seconds(), pooled)
+	return err
+}
+
+// Refill generates and reserves codes in batches until the pool holds Size codes
+func (p *CodePool) Refill(ctx context.Context) error {
+	length, err := db.RDB.LLen(ctx, codePoolKey).Result()
+	if err != nil {
+		return err
+	}
+	for missing := p.Size - int(length); missing > 0; {
+		n := missing
+		if n > codePoolBatchSize {
+			n = codePoolBatchSize
+		}
+		candidates, err := p.Codes.Candidates(ctx, n)
+		if err != nil {
+			return err
+		}
+
+		reserved, err := p.reserve(ctx, candidates)
+		if err != nil {
+			return err
+		}
+		p.Codes.recordCandidates(len(candidates), len(candidates)-len(reserved))
+		if len(reserved) == 0 {
+			return ErrShortCodeExhausted
+		}
+
+		values := make([]interface{}, len(reserved))
+		for i, code := range reserved {
+			values[i] = code
+		}
+		if err := db.RDB.RPush(ctx, codePoolKey, values...).Err(); err != nil {
+			return err
+		}
+		missing -= len(reserved)
+	}
+	return nil
+}
+
+// reserveCodes reserves the free candidates and returns them
+func reserveCodes(ctx context.Context, candidates []string) ([]string, error) {
+	rows, err := db.DB.Query(ctx, reserveCodesSQL, candidates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reserved []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		reserved = append(reserved, code)
+	}
+	return reserved, rows.Err()
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gochop/backend/internal/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPool returns a pool of the given size drawing the given codes, which
+// reserves every candidate except the taken ones
+func newTestPool(size int, codes []string, taken ...string) *CodePool {
+	return &CodePool{
+		Codes: &ShortCodeService{Generator: &stubGenerator{codes: codes}, length: defaultShortCodeLength},
+		Size:  size,
+		reserve: func(ctx context.Context, candidates []string) ([]string, error) {
+			var reserved []string
+			for _, code := range candidates {
+				if !containsString(taken, code) {
+					reserved = append(reserved, code)
+				}
+			}
+			return reserved, nil
+		},
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTakeHandsOutCodesInOrder(t *testing.T) {
+	server := useTestRedis(t)
+	server.RPush(codePoolKey, "aaaa", "bbbb")
+	p := newTestPool(2, nil)
+
+	for _, want := range []string{"aaaa", "bbbb"} {
+		code, err := p.Take(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Errorf("Take = %q, want %q", code, want)
+		}
+	}
+	if server.Exists(codePoolKey) {
+		t.Errorf("taken codes are still in the pool")
+	}
+}
+
+func TestTakeFromEmptyPoolWakesRefiller(t *testing.T) {
+	useTestRedis(t)
+	p := newTestPool(2, nil)
+
+	if _, err := p.Take(context.Background()); !errors.Is(err, ErrCodePoolEmpty) {
+		t.Fatalf("err = %v, want %v", err, ErrCodePoolEmpty)
+	}
+	select {
+	case <-p.wake:
+	default:
+		t.Errorf("refiller was not woken")
+	}
+}
+
+func TestTakeFromDisabledPool(t *testing.T) {
+	p := newTestPool(0, nil)
+	if _, err := p.Take(context.Background()); !errors.Is(err, ErrCodePoolEmpty) {
+		t.Errorf("err = %v, want %v", err, ErrCodePoolEmpty)
+	}
+}
+
+func TestRefillPushesOnlyReservedCodes(t *testing.T) {
+	server := useTestRedis(t)
+	server.RPush(codePoolKey, "aaaa")
+	p := newTestPool(4, []string{"bbbb", "cccc", "dddd", "eeee", "ffff"}, "cccc")
+
+	if err := p.Refill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	pooled, err := server.List(codePoolKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Three codes are missing; cccc is taken, so a second batch draws eeee
+	if got := strings.Join(pooled, ","); got != "aaaa,bbbb,dddd,eeee" {
+		t.Errorf("pool = %s, want aaaa,bbbb,dddd,eeee", got)
+	}
+}
+
+func TestRefillFullPoolDrawsNothing(t *testing.T) {
+	server := useTestRedis(t)
+	server.RPush(codePoolKey, "aaaa", "bbbb")
+	g := &stubGenerator{codes: []string{"cccc"}}
+	p := newTestPool(2, nil)
+	p.Codes.Generator = g
+
+	if err := p.Refill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if g.calls != 0 {
+		t.Errorf("generated %d codes for a full pool", g.calls)
+	}
+}
+
+func TestRefillStopsWhenEveryCandidateIsTaken(t *testing.T) {
+	server := useTestRedis(t)
+	p := newTestPool(2, []string{"aaaa", "bbbb"}, "aaaa", "bbbb")
+
+	if err := p.Refill(context.Background()); !errors.Is(err, ErrShortCodeExhausted) {
+		t.Errorf("err = %v, want %v", err, ErrShortCodeExhausted)
+	}
+	if server.Exists(codePoolKey) {
+		t.Errorf("taken codes were pushed to the pool")
+	}
+}
+
+func TestReserveCodesSkipsUsedAndReservedCodes(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	suffix := time.Now().UnixNano()
+	used := fmt.Sprintf("used%d", suffix)
+	reserved := fmt.Sprintf("rsvd%d", suffix)
+	free := fmt.Sprintf("free%d", suffix)
+	t.Cleanup(func() {
+		db.DB.Exec(ctx, "DELETE FROM links WHERE short_code = $1", used)
+		db.DB.Exec(ctx, "DELETE FROM reserved_codes WHERE code = ANY($1)", []string{used, reserved, free})
+	})
+
+	if _, err := db.DB.Exec(ctx, "INSERT INTO links (short_code, long_url) VALUES ($1, 'https://example.com')", used); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB.Exec(ctx, "INSERT INTO reserved_codes (code) VALUES ($1)", reserved); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := reserveCodes(ctx, []string{used, reserved, free})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != free {
+		t.Errorf("reserveCodes = %v, want [%s]", got, free)
+	}
+}
+
+func TestSweepKeepsPooledAndRecentReservations(t *testing.T) {
+	useTestDB(t)
+	server := useTestRedis(t)
+	ctx := context.Background()
+	suffix := time.Now().UnixNano()
+	stale := fmt.Sprintf("stale%d", suffix)
+	pooled := fmt.Sprintf("pooled%d", suffix)
+	recent := fmt.Sprintf("recent%d", suffix)
+	codes := []string{stale, pooled, recent}
+	t.Cleanup(func() {
+		db.DB.Exec(ctx, "DELETE FROM reserved_codes WHERE code = ANY($1)", codes)
+	})
+
+	old := time.Now().Add(-2 * staleReservationAge)
+	for code, reservedAt := range map[string]time.Time{stale: old, pooled: old, recent: time.Now()} {
+		if _, err := db.DB.Exec(ctx, "INSERT INTO reserved_codes (code, reserved_at) VALUES ($1, $2)", code, reservedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.RPush(codePoolKey, pooled)
+
+	if err := newTestPool(1, nil).sweep(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range codes {
+		var exists bool
+		if err := db.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM reserved_codes WHERE code = $1)", code).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if want := code != stale; exists != want {
+			t.Errorf("reservation of %s kept = %v, want %v", code, exists, want)
+		}
+	}
+}
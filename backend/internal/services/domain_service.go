@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gochop/backend/internal/db"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// DomainVerificationPrefix is prepended to a domain's hostname to get the
+	// name of the TXT record proving ownership
+	DomainVerificationPrefix = "_gochop-verification."
+
+	// domainVerificationValue prefixes the token in the TXT record value
+	domainVerificationValue = "gochop-verification="
+
+	customHostsRefreshInterval = time.Minute
+)
+
+var (
+	// ErrDomainExists is returned when the user already added the hostname or
+	// another user has verified it
+	ErrDomainExists = errors.New("this domain is already registered")
+
+	// ErrDomainNotFound is returned when the domain does not exist or belongs to another user
+	ErrDomainNotFound = errors.New("domain not found")
+
+	// ErrDomainNotVerified is returned when the TXT record is missing or wrong
+	ErrDomainNotVerified = errors.New("verification TXT record not found")
+
+	// ErrDomainInUse is returned when deleting a domain that still has links
+	ErrDomainInUse = errors.New("domain still has links")
+)
+
+var validHostname = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// TXTResolver looks up DNS TXT records. net.DefaultResolver satisfies it;
+// tests can substitute a stub.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Domain is a custom hostname a user can create links on
+type Domain struct {
+	ID                int        `json:"id"`
+	Hostname          string     `json:"hostname"`
+	Verified          bool       `json:"verified"`
+	VerifiedAt        *time.Time `json:"verified_at"`
+	VerificationName  string     `json:"verification_record_name"`
+	VerificationValue string     `json:"verification_record_value"`
+	CreatedAt         time.Time  `json:"created_at"`
+
+	// FallbackURL is where expired and unknown links on the domain redirect to
+	FallbackURL *string `json:"fallback_url"`
+}
+
+// DomainService handles custom domains. The set of verified hostnames is
+// kept in memory for the redirect path and reloaded periodically and after
+// every change.
+type DomainService struct {
+	Resolver TXTResolver
+
+	mu       sync.RWMutex
+	hosts    map[string]bool
+	loadedAt time.Time
+}
+
+// NewDomainService creates a new domain service using the system resolver
+func NewDomainService() *DomainService {
+	return &DomainService{Resolver: net.DefaultResolver}
+}
+
+// NormalizeHostname lower-cases a hostname and checks that it is valid
+func NormalizeHostname(hostname string) (string, error) {
+	hostname = NormalizeDomain(hostname)
+	if len(hostname) > 253 || !validHostname.MatchString(hostname) {
+		return "", fmt.Errorf("invalid hostname")
+	}
+	return hostname, nil
+}
+
+// newVerificationToken returns a random token for the TXT record
+func newVerificationToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// scanDomain reads a row of id, hostname, verification_token, verified_at,
+// created_at, fallback_url
+func scanDomain(row pgx.Row) (*Domain, error) {
+	var domain Domain
+	var token string
+	if err := row.Scan(&domain.ID, &domain.Hostname, &token, &domain.VerifiedAt, &domain.CreatedAt, &domain.FallbackURL); err != nil {
+		return nil, err
+	}
+	domain.Verified = domain.VerifiedAt != nil
+	domain.VerificationName = DomainVerificationPrefix + domain.Hostname
+	domain.VerificationValue = domainVerificationValue + token
+	return &domain, nil
+}
+
+// ListDomains returns a user's domains
+func (s *DomainService) ListDomains(ctx context.Context, userID string) ([]Domain, error) {
+	rows, err := db.DB.Query(ctx, `
+		SELECT id, hostname, verification_token, verified_at, created_at, fallback_url
+		FROM domains WHERE user_id = $1 ORDER BY hostname`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []Domain{}
+	for rows.Next() {
+		domain, err := scanDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, *domain)
+	}
+	return domains, rows.Err()
+}
+
+// AddDomain registers an unverified domain for a user. The hostname must
+// already be normalized. Several users may claim the same hostname until one
+// of them verifies it; after that it can no longer be added.
+func (s *DomainService) AddDomain(ctx context.Context, userID, hostname string) (*Domain, error) {
+	token, err := newVerificationToken()
+	if err != nil {
+		return nil, err
+	}
+	domain, err := scanDomain(db.DB.QueryRow(ctx, `
+		INSERT INTO domains (user_id, hostname, verification_token)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM domains WHERE hostname = $2 AND verified_at IS NOT NULL)
+		ON CONFLICT (user_id, hostname) DO NOTHING
+		RETURNING id, hostname, verification_token, verified_at, created_at, fallback_url`, userID, hostname, token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDomainExists
+	}
+	return domain, err
+}
+
+// VerifyDomain checks the domain's TXT record and marks it verified
+func (s *DomainService) VerifyDomain(ctx context.Context, userID string, domainID int) (*Domain, error) {
+	domain, err := scanDomain(db.DB.QueryRow(ctx, `
+		SELECT id, hostname, verification_token, verified_at, created_at, fallback_url
+		FROM domains WHERE id = $1 AND user_id = $2`, domainID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDomainNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if domain.Verified {
+		return domain, nil
+	}
+
+	if !s.hasVerificationRecord(ctx, domain) {
+		return nil, ErrDomainNotVerified
+	}
+
+	// The first claim to be verified wins; the other users' claims are dropped
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	domain, err = scanDomain(tx.QueryRow(ctx, `
+		UPDATE domains SET verified_at = NOW()
+		WHERE id = $1
+			AND NOT EXISTS (SELECT 1 FROM domains WHERE hostname = $2 AND verified_at IS NOT NULL)
+		RETURNING id, hostname, verification_token, verified_at, created_at, fallback_url`, domainID, domain.Hostname))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDomainExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM domains WHERE hostname = $1 AND id <> $2 AND verified_at IS NULL`, domain.Hostname, domainID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return domain, s.reload(ctx)
+}
+
+// hasVerificationRecord reports whether the domain's TXT record holds its
+// verification value. Lookup failures count as a missing record.
+func (s *DomainService) hasVerificationRecord(ctx context.Context, domain *Domain) bool {
+	records, err := s.Resolver.LookupTXT(ctx, domain.VerificationName)
+	if err != nil {
+		return false
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == domain.VerificationValue {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteDomain removes a user's domain. Domains that still have links cannot
+// be deleted.
+func (s *DomainService) DeleteDomain(ctx context.Context, userID string, domainID int) error {
+	// Check ownership first so other users' domains are reported as not
+	// found rather than revealing whether they have links
+	var inUse bool
+	err := db.DB.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM links WHERE domain_id = d.id)
+		FROM domains d WHERE d.id = $1 AND d.user_id = $2`, domainID, userID).Scan(&inUse)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrDomainNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrDomainInUse
+	}
+
+	tag, err := db.DB.Exec(ctx, "DELETE FROM domains WHERE id = $1 AND user_id = $2", domainID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDomainNotFound
+	}
+	return s.reload(ctx)
+}
+
+// SetFallbackURL changes where expired and unknown links on a user's domain
+// redirect to; an empty URL removes it
+func (s *DomainService) SetFallbackURL(ctx context.Context, userID string, domainID int, fallbackURL string) (*Domain, error) {
+	domain, err := scanDomain(db.DB.QueryRow(ctx, `
+		UPDATE domains SET fallback_url = NULLIF($3, '') WHERE id = $1 AND user_id = $2
+		RETURNING id, hostname, verification_token, verified_at, created_at, fallback_url`, domainID, userID, fallbackURL))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDomainNotFound
+	}
+	return domain, err
+}
+
+// GetVerifiedDomainID returns the ID of a verified domain owned by the user
+func (s *DomainService) GetVerifiedDomainID(ctx context.Context, userID, hostname string) (int, error) {
+	var domainID int
+	err := db.DB.QueryRow(ctx, `
+		SELECT id FROM domains WHERE hostname = $1 AND user_id = $2 AND verified_at IS NOT NULL`,
+		NormalizeDomain(hostname), userID).Scan(&domainID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrDomainNotFound
+	}
+	return domainID, err
+}
+
+// reload replaces the in-memory set of verified hostnames
+func (s *DomainService) reload(ctx context.Context) error {
+	rows, err := db.DB.Query(ctx, "SELECT hostname FROM domains WHERE verified_at IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	hosts := make(map[string]bool)
+	for rows.Next() {
+		var hostname string
+		if err := rows.Scan(&hostname); err != nil {
+			return err
+		}
+		hosts[hostname] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.hosts = hosts
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// IsCustomHost reports whether the hostname is a verified custom domain
+func (s *DomainService) IsCustomHost(ctx context.Context, hostname string) bool {
+	s.mu.RLock()
+	stale := s.hosts == nil || time.Since(s.loadedAt) > customHostsRefreshInterval
+	s.mu.RUnlock()
+	if stale {
+		if err := s.reload(ctx); err != nil {
+			// Keep serving from the previous copy if the database is unavailable
+			s.mu.Lock()
+			s.loadedAt = time.Now()
+			s.mu.Unlock()
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hosts[NormalizeDomain(hostname)]
+}
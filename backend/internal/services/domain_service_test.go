@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// stubResolver answers TXT lookups from a fixed set of records
+type stubResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (r *stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.records[name], nil
+}
+
+func TestHasVerificationRecord(t *testing.T) {
+	domain := &Domain{
+		VerificationName:  DomainVerificationPrefix + "example.com",
+		VerificationValue: domainVerificationValue + "abc123",
+	}
+	tests := []struct {
+		name     string
+		resolver *stubResolver
+		want     bool
+	}{
+		{"match", &stubResolver{records: map[string][]string{domain.VerificationName: {"v=spf1 -all", " gochop-verification=abc123 "}}}, true},
+		{"mismatch", &stubResolver{records: map[string][]string{domain.VerificationName: {"gochop-verification=other"}}}, false},
+		{"no record", &stubResolver{}, false},
+		{"lookup error", &stubResolver{err: errors.New("no such host")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &DomainService{Resolver: tt.resolver}
+			if got := s.hasVerificationRecord(context.Background(), domain); got != tt.want {
+				t.Errorf("hasVerificationRecord = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyDomain(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	suffix := time.Now().UnixNano()
+	hostname := fmt.Sprintf("verify-%d.example.com", suffix)
+	first := createTestUser(t, fmt.Sprintf("first-%d@example.com", suffix))
+	second := createTestUser(t, fmt.Sprintf("second-%d@example.com", suffix))
+
+	resolver := &stubResolver{records: map[string][]string{}}
+	s := &DomainService{Resolver: resolver}
+	firstClaim, err := s.AddDomain(ctx, first, hostname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondClaim, err := s.AddDomain(ctx, second, hostname)
+	if err != nil {
+		t.Fatalf("second unverified claim: %v", err)
+	}
+
+	resolver.err = errors.New("no such host")
+	if _, err := s.VerifyDomain(ctx, first, firstClaim.ID); !errors.Is(err, ErrDomainNotVerified) {
+		t.Errorf("lookup error: err = %v, want %v", err, ErrDomainNotVerified)
+	}
+
+	resolver.err = nil
+	resolver.records[firstClaim.VerificationName] = []string{domainVerificationValue + "wrong"}
+	if _, err := s.VerifyDomain(ctx, first, firstClaim.ID); !errors.Is(err, ErrDomainNotVerified) {
+		t.Errorf("mismatch: err = %v, want %v", err, ErrDomainNotVerified)
+	}
+
+	// Both claimants publish their record; whoever verifies first keeps the hostname
+	resolver.records[firstClaim.VerificationName] = []string{firstClaim.VerificationValue, secondClaim.VerificationValue}
+	verified, err := s.VerifyDomain(ctx, first, firstClaim.ID)
+	if err != nil {
+		t.Fatalf("match: %v", err)
+	}
+	if !verified.Verified || verified.VerifiedAt == nil {
+		t.Errorf("match: domain not marked verified")
+	}
+	if !s.IsCustomHost(ctx, hostname) {
+		t.Errorf("match: %s not served as a custom host", hostname)
+	}
+
+	if _, err := s.VerifyDomain(ctx, second, secondClaim.ID); !errors.Is(err, ErrDomainNotFound) {
+		t.Errorf("losing claim: err = %v, want %v", err, ErrDomainNotFound)
+	}
+	if _, err := s.AddDomain(ctx, second, hostname); !errors.Is(err, ErrDomainExists) {
+		t.Errorf("claim after verification: err = %v, want %v", err, ErrDomainExists)
+	}
+}
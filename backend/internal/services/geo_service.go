@@ -11,14 +11,16 @@ import (
 
 // GeoLocation represents geographic location data
 type GeoLocation struct {
-	Country string `json:"country"`
-	Region  string `json:"region"`
-	City    string `json:"city"`
+	Country     string `json:"country"`
+	CountryCode string `json:"country_code,omitempty"` // ISO 3166-1 alpha-2, when known
+	Region      string `json:"region"`
+	City        string `json:"city"`
 }
 
 // IPAPIResponse represents the response from ipapi.co
 type IPAPIResponse struct {
 	Country     string `json:"country_name"`
+	CountryCode string `json:"country_code"`
 	Region      string `json:"region"`
 	City        string `json:"city"`
 	Error       bool   `json:"error"`
@@ -63,9 +65,10 @@ func GetLocationFromIP(ipAddress string) (*GeoLocation, error) {
 	}
 
 	return &GeoLocation{
-		Country: getStringOrDefault(ipData.Country, "Unknown"),
-		Region:  getStringOrDefault(ipData.Region, "Unknown"),
-		City:    getStringOrDefault(ipData.City, "Unknown"),
+		Country:     getStringOrDefault(ipData.Country, "Unknown"),
+		CountryCode: ipData.CountryCode,
+		Region:      getStringOrDefault(ipData.Region, "Unknown"),
+		City:        getStringOrDefault(ipData.City, "Unknown"),
 	}, nil
 }
 
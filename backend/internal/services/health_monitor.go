@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"gochop/backend/internal/db"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	healthCheckTimeout   = 10 * time.Second
+	healthCheckBatchSize = 1000 // links loaded from the database at a time
+	healthCheckUserAgent = "GoChop-LinkChecker/1.0 (+destination health monitor)"
+	healthMonitorLockKey = "health_monitor:lock"
+)
+
+// Coarse reasons a health check failed without a response. Owners only see
+// these, never the underlying error, so the monitor cannot be used to probe
+// networks it can reach.
+const (
+	HealthErrorTimeout          = "timeout"
+	HealthErrorDNS              = "dns_error"
+	HealthErrorRefused          = "connection_refused"
+	HealthErrorTLS              = "tls_error"
+	HealthErrorBlockedAddress   = "blocked_address"
+	HealthErrorTooManyRedirects = "too_many_redirects"
+	HealthErrorConnection       = "connection_failed"
+)
+
+// LinkHealth is the latest health check result for a link's destination.
+// Error is one of the HealthError constants.
+type LinkHealth struct {
+	StatusCode          *int      `json:"status_code"`
+	LatencyMS           int       `json:"latency_ms"`
+	Error               *string   `json:"error"`
+	IsBroken            bool      `json:"is_broken"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	CheckedAt           time.Time `json:"checked_at"`
+}
+
+// HealthMonitor periodically checks link destinations. Checks run at most
+// Concurrency at a time, and requests to the same host are made one after the
+// other with at least HostDelay between them.
+type HealthMonitor struct {
+	Client      *http.Client
+	Interval    time.Duration
+	Concurrency int
+	HostDelay   time.Duration
+}
+
+// NewHealthMonitor creates a health monitor configured from the environment:
+// HEALTH_CHECK_INTERVAL (default 6h, 0 disables the monitor),
+// HEALTH_CHECK_CONCURRENCY (default 10) and HEALTH_CHECK_HOST_DELAY (default 2s)
+func NewHealthMonitor() *HealthMonitor {
+	m := &HealthMonitor{
+		Client:      newHealthCheckClient(),
+		Interval:    6 * time.Hour,
+		Concurrency: 10,
+		HostDelay:   2 * time.Second,
+	}
+	if interval, err := time.ParseDuration(os.Getenv("HEALTH_CHECK_INTERVAL")); err == nil && interval >= 0 {
+		m.Interval = interval
+	}
+	if concurrency, err := strconv.Atoi(os.Getenv("HEALTH_CHECK_CONCURRENCY")); err == nil && concurrency > 0 {
+		m.Concurrency = concurrency
+	}
+	if delay, err := time.ParseDuration(os.Getenv("HEALTH_CHECK_HOST_DELAY")); err == nil && delay >= 0 {
+		m.HostDelay = delay
+	}
+	return m
+}
+
+// Run checks links every Interval until the context is cancelled. Only one
+// instance runs a round at a time, coordinated through Redis.
+func (m *HealthMonitor) Run(ctx context.Context) {
+	if m.Interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		if acquired, _ := db.RDB.SetNX(ctx, healthMonitorLockKey, os.Getpid(), m.Interval).Result(); acquired {
+			if err := m.CheckDue(ctx); err != nil {
+				log.Printf("Health monitor: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// healthTarget is a link due for a health check
+type healthTarget struct {
+	linkID  int
+	longURL string
+}
+
+// CheckDue checks every live link whose last check is older than Interval.
+// Links are loaded in batches until none are left due, so large installs are
+// fully covered each round.
+func (m *HealthMonitor) CheckDue(ctx context.Context) error {
+	cutoff := time.Now().Add(-m.Interval)
+	// Links whose result could not be saved stay due; remember what this
+	// round already checked so they do not keep it going
+	checked := make(map[int]bool)
+	for {
+		targets, err := dueHealthTargets(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		fresh := targets[:0:0]
+		for _, target := range targets {
+			if !checked[target.linkID] {
+				checked[target.linkID] = true
+				fresh = append(fresh, target)
+			}
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		m.checkTargets(ctx, fresh)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(targets) < healthCheckBatchSize {
+			return nil
+		}
+	}
+}
+
+// dueHealthTargets loads the next batch of web links last checked before
+// cutoff, least recently checked first
+func dueHealthTargets(ctx context.Context, cutoff time.Time) ([]healthTarget, error) {
+	// mailto: and tel: links cannot be checked and would otherwise be due forever
+	query := `
+		SELECT l.id, l.long_url
+		FROM links l
+		LEFT JOIN link_health h ON h.link_id = l.id
+		WHERE l.is_active
+			AND (l.expires_at IS NULL OR l.expires_at > NOW())
+			AND (l.active_from IS NULL OR l.active_from <= NOW())
+			AND l.long_url ~* '^https?://'
+			AND (h.checked_at IS NULL OR h.checked_at < $1)
+		ORDER BY h.checked_at NULLS FIRST
+		LIMIT $2
+	`
+	rows, err := db.DB.Query(ctx, query, cutoff, healthCheckBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []healthTarget
+	for rows.Next() {
+		var target healthTarget
+		if err := rows.Scan(&target.linkID, &target.longURL); err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+// checkTargets checks a batch of links, grouped by host so each host is
+// visited by a single worker
+func (m *HealthMonitor) checkTargets(ctx context.Context, targets []healthTarget) {
+	byHost := make(map[string][]healthTarget)
+	for _, target := range targets {
+		u, err := url.Parse(target.longURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		byHost[u.Hostname()] = append(byHost[u.Hostname()], target)
+	}
+
+	hosts := make(chan []healthTarget)
+	var wg sync.WaitGroup
+	for i := 0; i < m.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for targets := range hosts {
+				m.checkHost(ctx, targets)
+			}
+		}()
+	}
+	for _, targets := range byHost {
+		hosts <- targets
+	}
+	close(hosts)
+	wg.Wait()
+}
+
+// checkHost checks the links of one host in turn, pausing between requests
+func (m *HealthMonitor) checkHost(ctx context.Context, targets []healthTarget) {
+	for i, target := range targets {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(m.HostDelay):
+			}
+		}
+		health := m.CheckURL(ctx, target.longURL)
+		if err := saveLinkHealth(ctx, target.linkID, health); err != nil {
+			log.Printf("Health monitor: could not save result for link %d: %v", target.linkID, err)
+		}
+	}
+}
+
+// CheckURL requests a destination and reports its health. A HEAD request is
+// tried first; servers that reject HEAD are retried with GET. Redirects are
+// followed, so the final response decides.
+func (m *HealthMonitor) CheckURL(ctx context.Context, destination string) *LinkHealth {
+	start := time.Now()
+	status, err := m.request(ctx, http.MethodHead, destination)
+	if err == nil && status >= 400 && status != http.StatusTooManyRequests {
+		status, err = m.request(ctx, http.MethodGet, destination)
+	}
+
+	health := &LinkHealth{
+		LatencyMS: int(time.Since(start).Milliseconds()),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		reason := classifyHealthError(err)
+		health.Error = &reason
+		health.IsBroken = true
+		return health
+	}
+	health.StatusCode = &status
+	// 429 means we were rate limited, not that the destination is gone
+	health.IsBroken = status >= 400 && status != http.StatusTooManyRequests
+	return health
+}
+
+// request sends a single request and returns the response status
+func (m *HealthMonitor) request(ctx context.Context, method, destination string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", healthCheckUserAgent)
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, nil
+}
+
+// classifyHealthError maps a failed request to one of the HealthError reasons
+func classifyHealthError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.Is(err, errBlockedAddress):
+		return HealthErrorBlockedAddress
+	case errors.Is(err, errTooManyRedirects):
+		return HealthErrorTooManyRedirects
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return HealthErrorTimeout
+	case errors.As(err, &dnsErr):
+		return HealthErrorDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return HealthErrorRefused
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &recordErr):
+		return HealthErrorTLS
+	default:
+		return HealthErrorConnection
+	}
+}
+
+var (
+	errBlockedAddress   = errors.New("destination resolves to a non-public address")
+	errTooManyRedirects = errors.New("too many redirects")
+)
+
+// healthCheckMaxRedirects caps the redirects followed for a single check
+const healthCheckMaxRedirects = 10
+
+// nonPublicPrefixes are address ranges the monitor never connects to:
+// loopback, private, shared, link-local (including cloud metadata services),
+// multicast and reserved space
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// isPublicAddress reports whether an IP address is publicly routable
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// rejectNonPublicAddress is a net.Dialer Control function. It runs after DNS
+// resolution for every connection, redirects included, so a hostname cannot
+// smuggle the monitor onto an internal address.
+func rejectNonPublicAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errBlockedAddress
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isPublicAddress(addr) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// newHealthCheckClient creates the HTTP client used for health checks. It
+// only connects to public addresses and never goes through a proxy, which
+// would hide the destination's address from the dialer.
+func newHealthCheckClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: healthCheckTimeout,
+		Control: rejectNonPublicAddress,
+	}
+	return &http.Client{
+		Timeout: healthCheckTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: healthCheckTimeout,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= healthCheckMaxRedirects {
+				return errTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// saveLinkHealth records the latest check result for a link
+func saveLinkHealth(ctx context.Context, linkID int, health *LinkHealth) error {
+	query := `
+		INSERT INTO link_health (link_id, status_code, latency_ms, error, is_broken, consecutive_failures, checked_at)
+		VALUES ($1, $2, $3, $4, $5, CASE WHEN $5 THEN 1 ELSE 0 END, $6)
+		ON CONFLICT (link_id) DO UPDATE SET
+			status_code = EXCLUDED.status_code,
+			latency_ms = EXCLUDED.latency_ms,
+			error = EXCLUDED.error,
+			is_broken = EXCLUDED.is_broken,
+			consecutive_failures = CASE WHEN EXCLUDED.is_broken THEN link_health.consecutive_failures + 1 ELSE 0 END,
+			checked_at = EXCLUDED.checked_at
+	`
+	_, err := db.DB.Exec(ctx, query, linkID, health.StatusCode, health.LatencyMS, health.Error, health.IsBroken, health.CheckedAt)
+	return err
+}
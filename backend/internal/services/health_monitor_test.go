@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testMonitor returns a monitor whose client may reach the loopback test
+// servers; the production client refuses them
+func testMonitor(timeout time.Duration) *HealthMonitor {
+	return &HealthMonitor{Client: &http.Client{Timeout: timeout}}
+}
+
+func TestCheckURLStatusClassification(t *testing.T) {
+	tests := []struct {
+		name       string
+		headStatus int
+		getStatus  int
+		wantStatus int
+		wantGets   int32
+		wantBroken bool
+	}{
+		{"head ok", http.StatusOK, http.StatusOK, http.StatusOK, 0, false},
+		{"head no content", http.StatusNoContent, http.StatusOK, http.StatusNoContent, 0, false},
+		{"head rejected, get ok", http.StatusMethodNotAllowed, http.StatusOK, http.StatusOK, 1, false},
+		{"head forbidden, get ok", http.StatusForbidden, http.StatusOK, http.StatusOK, 1, false},
+		{"not found", http.StatusNotFound, http.StatusNotFound, http.StatusNotFound, 1, true},
+		{"server error", http.StatusInternalServerError, http.StatusBadGateway, http.StatusBadGateway, 1, true},
+		{"rate limited is not broken", http.StatusTooManyRequests, http.StatusOK, http.StatusTooManyRequests, 0, false},
+		{"get rate limited is not broken", http.StatusMethodNotAllowed, http.StatusTooManyRequests, http.StatusTooManyRequests, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gets atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("User-Agent") != healthCheckUserAgent {
+					t.Errorf("User-Agent = %q", r.Header.Get("User-Agent"))
+				}
+				if r.Method == http.MethodHead {
+					w.WriteHeader(tt.headStatus)
+					return
+				}
+				gets.Add(1)
+				w.WriteHeader(tt.getStatus)
+			}))
+			defer server.Close()
+
+			health := testMonitor(time.Second).CheckURL(context.Background(), server.URL)
+			if health.Error != nil {
+				t.Fatalf("Error = %q, want none", *health.Error)
+			}
+			if health.StatusCode == nil || *health.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %v, want %d", health.StatusCode, tt.wantStatus)
+			}
+			if health.IsBroken != tt.wantBroken {
+				t.Errorf("IsBroken = %v, want %v", health.IsBroken, tt.wantBroken)
+			}
+			if gets.Load() != tt.wantGets {
+				t.Errorf("GET requests = %d, want %d", gets.Load(), tt.wantGets)
+			}
+		})
+	}
+}
+
+func TestCheckURLFollowsRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusMovedPermanently)
+	}))
+	defer origin.Close()
+
+	health := testMonitor(time.Second).CheckURL(context.Background(), origin.URL)
+	if health.StatusCode == nil || *health.StatusCode != http.StatusNotFound || !health.IsBroken {
+		t.Errorf("got status %v broken %v, want the redirect target's 404", health.StatusCode, health.IsBroken)
+	}
+}
+
+func TestCheckURLErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL := closed.URL
+	closed.Close()
+	open := httptest.NewServer(http.NotFoundHandler())
+	defer open.Close()
+
+	tests := []struct {
+		name    string
+		monitor *HealthMonitor
+		url     string
+		want    string
+	}{
+		{"timeout", testMonitor(50 * time.Millisecond), slow.URL, HealthErrorTimeout},
+		{"connection refused", testMonitor(time.Second), closedURL, HealthErrorRefused},
+		{"loopback blocked", &HealthMonitor{Client: newHealthCheckClient()}, open.URL, HealthErrorBlockedAddress},
+		{"metadata address blocked", &HealthMonitor{Client: newHealthCheckClient()}, "http://169.254.169.254/latest/meta-data/", HealthErrorBlockedAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := tt.monitor.CheckURL(context.Background(), tt.url)
+			if !health.IsBroken {
+				t.Error("IsBroken = false, want true")
+			}
+			if health.StatusCode != nil {
+				t.Errorf("StatusCode = %d, want none", *health.StatusCode)
+			}
+			if health.Error == nil || *health.Error != tt.want {
+				t.Errorf("Error = %v, want %q", health.Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"::", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
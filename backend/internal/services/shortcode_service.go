@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"gochop/backend/internal/db"
+	"log"
+	"math/big"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// AlphabetBase62 is the default short code alphabet
+	AlphabetBase62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// AlphabetUnambiguous leaves out characters that are easily mistaken for
+	// one another when read or typed by hand: 0/O/o and 1/l/I
+	AlphabetUnambiguous = "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz"
+
+	defaultShortCodeLength = 6
+	maxShortCodeLength     = 16
+	shortCodeAttempts      = 10
+
+	// The code length grows by one when more than collisionThreshold percent of
+	// the last collisionWindow candidates were already taken
+	collisionWindow    = 100
+	collisionThreshold = 10
+)
+
+// ErrShortCodeExhausted is returned when no free code was found
+var ErrShortCodeExhausted = errors.New("could not generate a unique short code")
+
+var validAlphabet = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// profaneWords are rejected anywhere in a generated code, after undoing
+// common digit-for-letter substitutions
+var profaneWords = []string{
+	"anal", "anus", "arse", "bitch", "boob", "butt", "cock", "coon", "crap", "cum",
+	"cunt", "damn", "dick", "dildo", "dyke", "fag", "fuck", "hell", "homo",
+	"jizz", "kike", "kkk", "nazi", "nigg", "penis", "piss", "poop", "porn", "pussy",
+	"rape", "sex", "shit", "slut", "spic", "tit", "twat", "vagina", "wank", "whore",
+}
+
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "9", "g", "_", "", "-", "")
+
+// containsProfanity reports whether a code spells out an offensive word
+func containsProfanity(code string) bool {
+	normalized := leetReplacer.Replace(strings.ToLower(code))
+	for _, word := range profaneWords {
+		if strings.Contains(normalized, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// CodeGenerator produces candidate short codes of the requested length.
+// attempt counts the candidates already rejected for the same link, so
+// deterministic strategies can derive a different code after a collision.
+type CodeGenerator interface {
+	Generate(ctx context.Context, longURL string, length, attempt int) (string, error)
+}
+
+// RandomGenerator draws every character uniformly from Alphabet
+type RandomGenerator struct {
+	Alphabet string
+}
+
+// Generate returns a random code
+func (g RandomGenerator) Generate(ctx context.Context, longURL string, length, attempt int) (string, error) {
+	max := big.NewInt(int64(len(g.Alphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = g.Alphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// HashGenerator derives the code from a SHA-256 hash of the destination, so
+// the same URL gets the same code while it is free
+type HashGenerator struct {
+	Alphabet string
+}
+
+// Generate returns the hash-based code, salted with the attempt after a collision
+func (g HashGenerator) Generate(ctx context.Context, longURL string, length, attempt int) (string, error) {
+	input := longURL
+	if attempt > 0 {
+		input += "#" + strconv.Itoa(attempt)
+	}
+	sum := sha256.Sum256([]byte(input))
+	code := encodeBase(new(big.Int).SetBytes(sum[:]), g.Alphabet)
+	if len(code) > length {
+		code = code[:length]
+	}
+	return code, nil
+}
+
+// SequenceGenerator encodes the next value of the short_code_seq Postgres
+// sequence in Alphabet. Codes are short and never repeat, but are guessable,
+// and their length grows with the sequence instead of being configured.
+type SequenceGenerator struct {
+	Alphabet string
+}
+
+// Generate returns the code for the next sequence value
+func (g SequenceGenerator) Generate(ctx context.Context, longURL string, length, attempt int) (string, error) {
+	codes, err := g.GenerateBatch(ctx, 1)
+	if err != nil {
+		return "", err
+	}
+	return codes[0], nil
+}
+
+// GenerateBatch returns the codes for the next n sequence values, drawn in a
+// single query
+func (g SequenceGenerator) GenerateBatch(ctx context.Context, n int) ([]string, error) {
+	rows, err := db.DB.Query(ctx, "SELECT nextval('short_code_seq') FROM generate_series(1, $1)", n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := make([]string, 0, n)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		codes = append(codes, encodeBase(big.NewInt(id), g.Alphabet))
+	}
+	return codes, rows.Err()
+}
+
+// BatchGenerator is implemented by strategies that can produce many
+// destination-independent codes at once. Every code they return is distinct
+// and used up, so callers ask for exactly as many as they need.
+type BatchGenerator interface {
+	GenerateBatch(ctx context.Context, n int) ([]string, error)
+}
+
+// encodeBase writes n in the base given by the alphabet's length
+func encodeBase(n *big.Int, alphabet string) string {
+	if n.Sign() == 0 {
+		return alphabet[:1]
+	}
+	base := big.NewInt(int64(len(alphabet)))
+	n = new(big.Int).Set(n)
+	digit := new(big.Int)
+	var b []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, base, digit)
+		b = append(b, alphabet[digit.Int64()])
+	}
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// ShortCodeService generates short codes with the configured strategy,
+// skipping profane and taken codes
+type ShortCodeService struct {
+	Generator CodeGenerator
+
+	mu         sync.Mutex
+	length     int
+	candidates int
+	collisions int
+}
+
+// NewShortCodeService creates a ShortCodeService configured from
+// SHORT_CODE_STRATEGY (random, hash or sequence), SHORT_CODE_LENGTH and
+// SHORT_CODE_ALPHABET (base62, unambiguous or the characters to use)
+func NewShortCodeService() *ShortCodeService {
+	alphabet := AlphabetBase62
+	switch value := os.Getenv("SHORT_CODE_ALPHABET"); value {
+	case "", "base62":
+	case "unambiguous":
+		alphabet = AlphabetUnambiguous
+	default:
+		if len(value) >= 2 && validAlphabet.MatchString(value) && !hasDuplicateChars(value) {
+			alphabet = value
+		} else {
+			log.Printf("Ignoring invalid SHORT_CODE_ALPHABET %q, using base62", value)
+		}
+	}
+
+	s := &ShortCodeService{length: defaultShortCodeLength}
+	if length, err := strconv.Atoi(os.Getenv("SHORT_CODE_LENGTH")); err == nil && length >= 4 && length <= maxShortCodeLength {
+		s.length = length
+	}
+
+	switch strategy := os.Getenv("SHORT_CODE_STRATEGY"); strategy {
+	case "", "random":
+		s.Generator = RandomGenerator{Alphabet: alphabet}
+	case "hash":
+		s.Generator = HashGenerator{Alphabet: alphabet}
+	case "sequence":
+		s.Generator = SequenceGenerator{Alphabet: alphabet}
+	default:
+		log.Printf("Ignoring unknown SHORT_CODE_STRATEGY %q, using random", strategy)
+		s.Generator = RandomGenerator{Alphabet: alphabet}
+	}
+	return s
+}
+
+// hasDuplicateChars reports whether any character occurs twice
+func hasDuplicateChars(s string) bool {
+	seen := make(map[rune]bool)
+	for _, r := range s {
+		if seen[r] {
+			return true
+		}
+		seen[r] = true
+	}
+	return false
+}
+
+// Length returns the length of newly generated codes
+func (s *ShortCodeService) Length() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.length
+}
+
+// recordCandidates tracks the collision rate, given how many of a number of
+// candidates were taken, and grows the code length when too many are. The
+// grown length lasts until restart.
+func (s *ShortCodeService) recordCandidates(candidates, taken int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candidates += candidates
+	s.collisions += taken
+	if s.candidates < collisionWindow {
+		return
+	}
+	if s.collisions*100 > s.candidates*collisionThreshold && s.length < maxShortCodeLength {
+		s.length++
+		log.Printf("Short code collision rate at %d%%, growing codes to %d characters", s.collisions*100/s.candidates, s.length)
+	}
+	s.candidates, s.collisions = 0, 0
+}
+
+// Generate returns a code for the destination that isTaken reports as free
+func (s *ShortCodeService) Generate(ctx context.Context, longURL string, isTaken func(code string) (bool, error)) (string, error) {
+	for attempt := 0; attempt < shortCodeAttempts; attempt++ {
+		code, err := s.Generator.Generate(ctx, longURL, s.Length(), attempt)
+		if err != nil {
+			return "", fmt.Errorf("could not generate short code: %w", err)
+		}
+		if containsProfanity(code) {
+			continue
+		}
+		taken, err := isTaken(code)
+		if err != nil {
+			return "", err
+		}
+		if taken {
+			s.recordCandidates(1, 1)
+			continue
+		}
+		s.recordCandidates(1, 0)
+		return code, nil
+	}
+	return "", ErrShortCodeExhausted
+}
+
+// Candidates returns up to n distinct codes that do not depend on a
+// destination, without checking whether they are taken. Batch strategies are
+// asked for exactly the codes still missing, so no sequence value is drawn
+// beyond the profane codes that have to be skipped.
+func (s *ShortCodeService) Candidates(ctx context.Context, n int) ([]string, error) {
+	if batch, ok := s.Generator.(BatchGenerator); ok {
+		codes := make([]string, 0, n)
+		for round := 0; len(codes) < n && round < shortCodeAttempts; round++ {
+			drawn, err := batch.GenerateBatch(ctx, n-len(codes))
+			if err != nil {
+				return nil, fmt.Errorf("could not generate short code: %w", err)
+			}
+			for _, code := range drawn {
+				if !containsProfanity(code) {
+					codes = append(codes, code)
+				}
+			}
+		}
+		return codes, nil
+	}
+
+	length := s.Length()
+	seen := make(map[string]bool, n)
+	codes := make([]string, 0, n)
+	for attempt := 0; len(codes) < n && attempt < 2*n; attempt++ {
+		code, err := s.Generator.Generate(ctx, "", length, attempt)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate short code: %w", err)
+		}
+		if seen[code] || containsProfanity(code) {
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
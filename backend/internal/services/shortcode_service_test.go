@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// stubGenerator returns its codes in order, one per call
+type stubGenerator struct {
+	codes []string
+	calls int
+}
+
+func (g *stubGenerator) Generate(ctx context.Context, longURL string, length, attempt int) (string, error) {
+	if g.calls >= len(g.codes) {
+		return "", errors.New("out of codes")
+	}
+	code := g.codes[g.calls]
+	g.calls++
+	return code, nil
+}
+
+func TestRandomGeneratorAlphabetAndLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		alphabet string
+		length   int
+	}{
+		{"base62", AlphabetBase62, 6},
+		{"unambiguous", AlphabetUnambiguous, 8},
+		{"custom", "ab", 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := RandomGenerator{Alphabet: tt.alphabet}
+			for i := 0; i < 50; i++ {
+				code, err := g.Generate(context.Background(), "", tt.length, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(code) != tt.length {
+					t.Fatalf("len(%q) = %d, want %d", code, len(code), tt.length)
+				}
+				for _, r := range code {
+					if !strings.ContainsRune(tt.alphabet, r) {
+						t.Fatalf("code %q has %q outside the alphabet", code, r)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUnambiguousAlphabetLeavesOutLookalikes(t *testing.T) {
+	for _, r := range "0Oo1lI" {
+		if strings.ContainsRune(AlphabetUnambiguous, r) {
+			t.Errorf("AlphabetUnambiguous contains %q", r)
+		}
+	}
+}
+
+func TestHashGenerator(t *testing.T) {
+	g := HashGenerator{Alphabet: AlphabetBase62}
+	ctx := context.Background()
+
+	first, _ := g.Generate(ctx, "https://example.com", 7, 0)
+	again, _ := g.Generate(ctx, "https://example.com", 7, 0)
+	retry, _ := g.Generate(ctx, "https://example.com", 7, 1)
+	other, _ := g.Generate(ctx, "https://example.org", 7, 0)
+
+	if len(first) != 7 {
+		t.Errorf("len(%q) = %d, want 7", first, len(first))
+	}
+	if first != again {
+		t.Errorf("same destination gave %q and %q", first, again)
+	}
+	if first == retry {
+		t.Errorf("retry after a collision repeated %q", first)
+	}
+	if first == other {
+		t.Errorf("different destinations both gave %q", first)
+	}
+}
+
+func TestEncodeBase(t *testing.T) {
+	tests := []struct {
+		n        int64
+		alphabet string
+		want     string
+	}{
+		{0, AlphabetBase62, "0"},
+		{61, AlphabetBase62, "z"},
+		{62, AlphabetBase62, "10"},
+		{5, "01", "101"},
+		{0, AlphabetUnambiguous, "2"},
+	}
+	for _, tt := range tests {
+		if got := encodeBase(big.NewInt(tt.n), tt.alphabet); got != tt.want {
+			t.Errorf("encodeBase(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestContainsProfanity(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"aB3xQ9", false},
+		{"xSHITx", true},
+		{"5h1t00", true},
+		{"f-u-c-k", true},
+		{"Kx7pQ2", false},
+	}
+	for _, tt := range tests {
+		if got := containsProfanity(tt.code); got != tt.want {
+			t.Errorf("containsProfanity(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestHasDuplicateChars(t *testing.T) {
+	if hasDuplicateChars(AlphabetBase62) || hasDuplicateChars(AlphabetUnambiguous) {
+		t.Error("built-in alphabets report duplicates")
+	}
+	if !hasDuplicateChars("abca") {
+		t.Error(`hasDuplicateChars("abca") = false`)
+	}
+}
+
+func TestGenerateRetriesCollisions(t *testing.T) {
+	tests := []struct {
+		name    string
+		codes   []string
+		taken   map[string]bool
+		want    string
+		wantErr error
+	}{
+		{"first free", []string{"aaaa", "bbbb"}, nil, "aaaa", nil},
+		{"skips taken", []string{"aaaa", "bbbb"}, map[string]bool{"aaaa": true}, "bbbb", nil},
+		{"skips profane", []string{"xsexx", "cccc"}, nil, "cccc", nil},
+		{
+			"gives up",
+			[]string{"t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7", "t8", "t9", "free"},
+			map[string]bool{"t0": true, "t1": true, "t2": true, "t3": true, "t4": true,
+				"t5": true, "t6": true, "t7": true, "t8": true, "t9": true},
+			"",
+			ErrShortCodeExhausted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ShortCodeService{Generator: &stubGenerator{codes: tt.codes}, length: defaultShortCodeLength}
+			got, err := s.Generate(context.Background(), "https://example.com", func(code string) (bool, error) {
+				return tt.taken[code], nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("code = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordCandidatesGrowsLength(t *testing.T) {
+	tests := []struct {
+		name       string
+		taken      int
+		wantLength int
+	}{
+		{"below threshold", collisionWindow * collisionThreshold / 100, defaultShortCodeLength},
+		{"above threshold", collisionWindow*collisionThreshold/100 + 1, defaultShortCodeLength + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ShortCodeService{length: defaultShortCodeLength}
+			s.recordCandidates(collisionWindow, tt.taken)
+			if s.Length() != tt.wantLength {
+				t.Errorf("Length() = %d, want %d", s.Length(), tt.wantLength)
+			}
+		})
+	}
+}
+
+func TestCandidatesAreDistinct(t *testing.T) {
+	s := &ShortCodeService{
+		Generator: &stubGenerator{codes: []string{"aaaa", "aaaa", "bbbb", "xsexx", "cccc", "dddd"}},
+		length:    defaultShortCodeLength,
+	}
+	codes, err := s.Candidates(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(codes, ",") != "aaaa,bbbb,cccc" {
+		t.Errorf("Candidates = %v, want [aaaa bbbb cccc]", codes)
+	}
+}
+
+// stubBatchGenerator hands out numbered codes in batches, like the sequence
+type stubBatchGenerator struct {
+	stubGenerator
+	drawn []int
+}
+
+func (g *stubBatchGenerator) GenerateBatch(ctx context.Context, n int) ([]string, error) {
+	g.drawn = append(g.drawn, n)
+	codes := make([]string, 0, n)
+	for i := 0; i < n && g.calls < len(g.codes); i++ {
+		codes = append(codes, g.codes[g.calls])
+		g.calls++
+	}
+	return codes, nil
+}
+
+func TestCandidatesDrawOnlyMissingBatchCodes(t *testing.T) {
+	g := &stubBatchGenerator{stubGenerator: stubGenerator{codes: []string{"aaaa", "xsexx", "bbbb", "cccc", "dddd"}}}
+	s := &ShortCodeService{Generator: g, length: defaultShortCodeLength}
+	codes, err := s.Candidates(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(codes, ",") != "aaaa,bbbb,cccc" {
+		t.Errorf("Candidates = %v, want [aaaa bbbb cccc]", codes)
+	}
+	if len(g.drawn) != 2 || g.drawn[0] != 3 || g.drawn[1] != 1 {
+		t.Errorf("drew batches %v, want [3 1] (one code replacing the profane one)", g.drawn)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gochop/backend/internal/db"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+)
+
+// maxTagsPerLink caps how many tags a single link may carry
+const maxTagsPerLink = 20
+
+var (
+	// ErrTagExists is returned when the user already has a tag with the name
+	ErrTagExists = errors.New("a tag with this name already exists")
+
+	// ErrTagNotFound is returned when the tag does not exist or belongs to another user
+	ErrTagNotFound = errors.New("tag not found")
+)
+
+var validTagName = regexp.MustCompile(`^[a-z0-9][a-z0-9 ._-]{0,49}$`)
+
+// Tag is a user's label for grouping links. LinkCount and ClickCount
+// aggregate over the links carrying the tag.
+type Tag struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	LinkCount  int       `json:"link_count"`
+	ClickCount int       `json:"click_count"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TagService handles link tags
+type TagService struct{}
+
+// NewTagService creates a new tag service
+func NewTagService() *TagService {
+	return &TagService{}
+}
+
+// NormalizeTagName trims and lower-cases a tag name and checks that it is valid
+func NormalizeTagName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !validTagName.MatchString(name) {
+		return "", fmt.Errorf("tag names must be 1-50 characters: letters, numbers, spaces, dots, hyphens or underscores")
+	}
+	return name, nil
+}
+
+// NormalizeTagNames normalizes a list of tag names, dropping duplicates
+func NormalizeTagNames(names []string) ([]string, error) {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, name := range names {
+		name, err := NormalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	if len(normalized) > maxTagsPerLink {
+		return nil, fmt.Errorf("a link can have at most %d tags", maxTagsPerLink)
+	}
+	return normalized, nil
+}
+
+// ListTags returns a user's tags with their link and click counts
+func (s *TagService) ListTags(ctx context.Context, userID string) ([]Tag, error) {
+	query := `
+		SELECT t.id, t.name, t.created_at,
+			(SELECT COUNT(*) FROM link_tags lt WHERE lt.tag_id = t.id) as link_count,
+			(SELECT COUNT(*) FROM link_tags lt
+				JOIN links l ON l.id = lt.link_id
+				JOIN analytics a ON a.short_code = l.short_code
+				WHERE lt.tag_id = t.id) as click_count
+		FROM tags t
+		WHERE t.user_id = $1
+		ORDER BY t.name
+	`
+	rows, err := db.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.CreatedAt, &tag.LinkCount, &tag.ClickCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// CreateTag creates a tag for a user
+func (s *TagService) CreateTag(ctx context.Context, userID, name string) (*Tag, error) {
+	query := `
+		INSERT INTO tags (user_id, name) VALUES ($1, $2)
+		ON CONFLICT (user_id, name) DO NOTHING
+		RETURNING id, name, created_at
+	`
+	var tag Tag
+	err := db.DB.QueryRow(ctx, query, userID, name).Scan(&tag.ID, &tag.Name, &tag.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTagExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// RenameTag renames one of a user's tags. A clash with another of the user's
+// tags is caught by the unique constraint, so concurrent renames to the same
+// name cannot both succeed.
+func (s *TagService) RenameTag(ctx context.Context, userID string, tagID int, name string) (*Tag, error) {
+	var tag Tag
+	err := db.DB.QueryRow(ctx, "UPDATE tags SET name = $3 WHERE id = $2 AND user_id = $1 RETURNING id, name, created_at", userID, tagID, name).
+		Scan(&tag.ID, &tag.Name, &tag.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTagNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return nil, ErrTagExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// DeleteTag deletes one of a user's tags, removing it from all links
+func (s *TagService) DeleteTag(ctx context.Context, userID string, tagID int) error {
+	tag, err := db.DB.Exec(ctx, "DELETE FROM tags WHERE id = $1 AND user_id = $2", tagID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// SetLinkTags replaces the tags of a link. Tags belong to the link's owner
+// and are created on first use. Names must already be normalized.
+func (s *TagService) SetLinkTags(ctx context.Context, tx pgx.Tx, shortCode string, names []string) error {
+	var linkID int
+	var ownerID string
+	err := tx.QueryRow(ctx, "SELECT id, COALESCE(user_id::text, '') FROM links WHERE short_code = $1", shortCode).Scan(&linkID, &ownerID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM link_tags WHERE link_id = $1", linkID); err != nil {
+		return err
+	}
+	if len(names) == 0 || ownerID == "" {
+		return nil
+	}
+
+	createSQL := `
+		INSERT INTO tags (user_id, name) SELECT $1, unnest($2::text[])
+		ON CONFLICT (user_id, name) DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, createSQL, ownerID, names); err != nil {
+		return err
+	}
+
+	assignSQL := `
+		INSERT INTO link_tags (link_id, tag_id)
+		SELECT $1, id FROM tags WHERE user_id = $2 AND name = ANY($3)
+	`
+	_, err = tx.Exec(ctx, assignSQL, linkID, ownerID, names)
+	return err
+}
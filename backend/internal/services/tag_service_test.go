@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRenameTagConflict(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, fmt.Sprintf("tags-%d@example.com", time.Now().UnixNano()))
+
+	s := &TagService{}
+	if _, err := s.CreateTag(ctx, userID, "news"); err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.CreateTag(ctx, userID, "sports")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.RenameTag(ctx, userID, other.ID, "news"); !errors.Is(err, ErrTagExists) {
+		t.Errorf("rename onto an existing name: err = %v, want %v", err, ErrTagExists)
+	}
+	if _, err := s.RenameTag(ctx, userID, other.ID+1000000, "weather"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("rename of a missing tag: err = %v, want %v", err, ErrTagNotFound)
+	}
+	renamed, err := s.RenameTag(ctx, userID, other.ID, "weather")
+	if err != nil || renamed.Name != "weather" {
+		t.Errorf("rename = %v, %v; want weather", renamed, err)
+	}
+}
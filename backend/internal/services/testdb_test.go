@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"gochop/backend/internal/db"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// useTestDB points db.DB at the migrated database in TEST_DATABASE_URL for
+// the duration of the test, skipping the test when it is not set
+func useTestDB(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Setenv("DATABASE_URL", url)
+	if err := db.RunMigrations(); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := pgxpool.Connect(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := db.DB
+	db.DB = pool
+	t.Cleanup(func() {
+		db.DB = previous
+		pool.Close()
+	})
+}
+
+// createTestUser inserts a user that is deleted, with everything it owns,
+// when the test ends
+func createTestUser(t *testing.T, email string) string {
+	t.Helper()
+	ctx := context.Background()
+	var id string
+	if err := db.DB.QueryRow(ctx, "INSERT INTO users (email) VALUES ($1) RETURNING id", email).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.DB.Exec(ctx, "DELETE FROM users WHERE id = $1", id)
+	})
+	return id
+}
@@ -0,0 +1,43 @@
+package services
+
+import "strings"
+
+// DeviceInfo is the result of parsing a User-Agent header
+type DeviceInfo struct {
+	DeviceType string `json:"device_type"` // mobile, tablet, desktop or bot
+	OS         string `json:"os"`          // ios, android, windows, macos, linux or unknown
+}
+
+// ParseUserAgent extracts the device type and operating system from a
+// User-Agent header. It is a lightweight heuristic, not a full UA database.
+func ParseUserAgent(userAgent string) DeviceInfo {
+	ua := strings.ToLower(userAgent)
+	info := DeviceInfo{DeviceType: "desktop", OS: "unknown"}
+
+	// Operating system (order matters: iOS and Android UAs also mention other systems)
+	switch {
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ipod"):
+		info.OS = "ios"
+	case strings.Contains(ua, "android"):
+		info.OS = "android"
+	case strings.Contains(ua, "windows"):
+		info.OS = "windows"
+	case strings.Contains(ua, "mac os x") || strings.Contains(ua, "macintosh"):
+		info.OS = "macos"
+	case strings.Contains(ua, "linux") || strings.Contains(ua, "cros"):
+		info.OS = "linux"
+	}
+
+	// Device type
+	switch {
+	case ua == "" || strings.Contains(ua, "bot") || strings.Contains(ua, "crawler") || strings.Contains(ua, "spider"):
+		info.DeviceType = "bot"
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(info.OS == "android" && !strings.Contains(ua, "mobile")):
+		info.DeviceType = "tablet"
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod"):
+		info.DeviceType = "mobile"
+	}
+
+	return info
+}
@@ -6,6 +6,20 @@ import (
 	"time"
 )
 
+// ExpiringSoonWindow is how close to its expiry a link counts as expiring
+// soon in the user's stats, and when clicks start renewing auto-renew links
+const ExpiringSoonWindow = 7 * 24 * time.Hour
+
+// ExpiringLink is a live link that expires within ExpiringSoonWindow.
+// ShortCode is the link's storage key; ShortURL is left for the handlers to
+// fill in.
+type ExpiringLink struct {
+	ShortCode string    `json:"short_code"`
+	ShortURL  string    `json:"short_url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	AutoRenew bool      `json:"auto_renew"`
+}
+
 // User represents a user in the system
 type User struct {
 	ID       string    `json:"id"`
@@ -148,15 +162,45 @@ func (s *UserService) GetUserStats(ctx context.Context, userID string) (map[stri
 	}
 	stats["total_clicks"] = totalClicks
 
-	// Get active links (non-expired)
+	// Get active links (live, non-expired and not deactivated)
 	var activeLinks int
-	activeQuery := "SELECT COUNT(*) FROM links WHERE user_id = $1 AND expires_at > NOW()"
+	activeQuery := "SELECT COUNT(*) FROM links WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW()) AND is_active AND (active_from IS NULL OR active_from <= NOW())"
 	err = db.DB.QueryRow(ctx, activeQuery, userID).Scan(&activeLinks)
 	if err != nil {
 		activeLinks = 0
 	}
 	stats["active_links"] = activeLinks
 
+	// Get expired links, which can still be renewed
+	var expiredLinks int
+	expiredQuery := "SELECT COUNT(*) FROM links WHERE user_id = $1 AND expires_at <= NOW()"
+	err = db.DB.QueryRow(ctx, expiredQuery, userID).Scan(&expiredLinks)
+	if err != nil {
+		expiredLinks = 0
+	}
+	stats["expired_links"] = expiredLinks
+
+	// Get live links expiring soon, so the dashboard can prompt a renewal
+	expiringLinks := []ExpiringLink{}
+	expiringQuery := `
+		SELECT short_code, expires_at, auto_renew
+		FROM links
+		WHERE user_id = $1 AND is_active AND expires_at > NOW() AND expires_at <= $2
+		ORDER BY expires_at
+	`
+	rows, err := db.DB.Query(ctx, expiringQuery, userID, time.Now().Add(ExpiringSoonWindow))
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var link ExpiringLink
+			if err := rows.Scan(&link.ShortCode, &link.ExpiresAt, &link.AutoRenew); err == nil {
+				expiringLinks = append(expiringLinks, link)
+			}
+		}
+	}
+	stats["expiring_soon"] = len(expiringLinks)
+	stats["expiring_links"] = expiringLinks
+
 	// Get most clicked link
 	var mostClickedLink string
 	var mostClicks int
@@ -198,6 +242,20 @@ func (s *UserService) isUserAdmin(email string) bool {
 	return false
 }
 
+// GetFallbackURL returns where a user's expired links redirect to when they
+// have no expired_redirect_url of their own, or "" if not set
+func (s *UserService) GetFallbackURL(ctx context.Context, userID string) (string, error) {
+	var fallbackURL string
+	err := db.DB.QueryRow(ctx, "SELECT COALESCE(fallback_url, '') FROM users WHERE id = $1", userID).Scan(&fallbackURL)
+	return fallbackURL, err
+}
+
+// SetFallbackURL replaces a user's fallback URL; an empty URL removes it
+func (s *UserService) SetFallbackURL(ctx context.Context, userID, fallbackURL string) error {
+	_, err := db.DB.Exec(ctx, "UPDATE users SET fallback_url = NULLIF($2, ''), updated_at = NOW() WHERE id = $1", userID, fallbackURL)
+	return err
+}
+
 // ListUsers returns all users (admin only)
 func (s *UserService) ListUsers(ctx context.Context) ([]User, error) {
 	query := `
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gochop/backend/internal/db"
+)
+
+// UTMParams holds the UTM tags appended to a destination at redirect time
+type UTMParams struct {
+	Source   string `json:"utm_source,omitempty"`
+	Medium   string `json:"utm_medium,omitempty"`
+	Campaign string `json:"utm_campaign,omitempty"`
+	Term     string `json:"utm_term,omitempty"`
+	Content  string `json:"utm_content,omitempty"`
+}
+
+// Validate checks that each tag is a reasonable length
+func (u *UTMParams) Validate() error {
+	if u == nil {
+		return nil
+	}
+	for name, value := range u.Values() {
+		if len(value) > 100 {
+			return fmt.Errorf("%s must be less than 100 characters", name)
+		}
+	}
+	return nil
+}
+
+// IsEmpty reports whether no tag is set
+func (u *UTMParams) IsEmpty() bool {
+	return u == nil || len(u.Values()) == 0
+}
+
+// Values returns the non-empty tags keyed by query parameter name
+func (u *UTMParams) Values() map[string]string {
+	values := make(map[string]string)
+	if u == nil {
+		return values
+	}
+	for name, value := range map[string]string{
+		"utm_source":   u.Source,
+		"utm_medium":   u.Medium,
+		"utm_campaign": u.Campaign,
+		"utm_term":     u.Term,
+		"utm_content":  u.Content,
+	} {
+		if value != "" {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// MergeUTM returns the link's tags with any unset tag filled in from the template
+func MergeUTM(link, template *UTMParams) *UTMParams {
+	merged := UTMParams{}
+	if template != nil {
+		merged = *template
+	}
+	if link != nil {
+		if link.Source != "" {
+			merged.Source = link.Source
+		}
+		if link.Medium != "" {
+			merged.Medium = link.Medium
+		}
+		if link.Campaign != "" {
+			merged.Campaign = link.Campaign
+		}
+		if link.Term != "" {
+			merged.Term = link.Term
+		}
+		if link.Content != "" {
+			merged.Content = link.Content
+		}
+	}
+	if merged.IsEmpty() {
+		return nil
+	}
+	return &merged
+}
+
+// UTMService handles user-level UTM templates
+type UTMService struct{}
+
+// NewUTMService creates a new UTM service
+func NewUTMService() *UTMService {
+	return &UTMService{}
+}
+
+// GetTemplate retrieves a user's UTM template, or nil if none is set
+func (s *UTMService) GetTemplate(ctx context.Context, userID string) (*UTMParams, error) {
+	var data []byte
+	err := db.DB.QueryRow(ctx, "SELECT utm_template FROM users WHERE id = $1", userID).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var template UTMParams
+	if err := json.Unmarshal(data, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// SetTemplate replaces a user's UTM template; an empty template removes it
+func (s *UTMService) SetTemplate(ctx context.Context, userID string, template *UTMParams) error {
+	var data interface{}
+	if !template.IsEmpty() {
+		encoded, err := json.Marshal(template)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+	_, err := db.DB.Exec(ctx, "UPDATE users SET utm_template = $2, updated_at = NOW() WHERE id = $1", userID, data)
+	return err
+}
@@ -36,7 +36,10 @@ const truncateUrl = (url: string, maxLength: number = 50) => {
 export default function DashboardPage() {
   const { data: session } = useSession();
   const [links, setLinks] = useState<LinkInfo[]>([]);
+  const [nextCursor, setNextCursor] = useState<string | null>(null);
+  const [total, setTotal] = useState(0);
   const [isLoading, setIsLoading] = useState(true);
+  const [isLoadingMore, setIsLoadingMore] = useState(false);
   const [error, setError] = useState("");
 
   useEffect(() => {
@@ -45,6 +48,30 @@ export default function DashboardPage() {
     }
   }, [session]);
 
+  // Fetch one page of the user's links (or all if admin) using NextAuth session
+  const fetchLinksPage = (cursor?: string) =>
+    session?.isAdmin ? api.getAllLinks(cursor) : api.getUserLinks(cursor);
+
+  const loadMoreLinks = async () => {
+    if (!nextCursor || isLoadingMore) return;
+    try {
+      setIsLoadingMore(true);
+      const response = await fetchLinksPage(nextCursor);
+      if (!response.ok) {
+        console.log("Failed to fetch more links");
+        return;
+      }
+      const data = await response.json();
+      setLinks((current) => [...current, ...(data?.links || [])]);
+      setNextCursor(data?.next_cursor || null);
+      setTotal(data?.total || 0);
+    } catch (err) {
+      console.log("Backend not available:", err);
+    } finally {
+      setIsLoadingMore(false);
+    }
+  };
+
   const fetchLinks = async () => {
     try {
       setIsLoading(true);
@@ -58,19 +85,21 @@ export default function DashboardPage() {
 
       // Try to fetch from backend, but gracefully handle failures
       try {
-        // Fetch user's links (or all if admin) using NextAuth session
-        const response = session.isAdmin
-          ? await api.getAllLinks()
-          : await api.getUserLinks();
+        // Fetch the first page of the user's links (or all if admin)
+        const response = await fetchLinksPage();
 
         if (!response.ok) {
           console.log("Failed to fetch links, showing empty state");
           setLinks([]);
+          setNextCursor(null);
           return;
         }
 
+        // The backend returns a page envelope: { links, next_cursor, total }
         const data = await response.json();
-        setLinks(data || []);
+        setLinks(data?.links || []);
+        setNextCursor(data?.next_cursor || null);
+        setTotal(data?.total || 0);
       } catch (backendError) {
         // Backend is not available or having issues
         console.log("Backend not available:", backendError);
@@ -306,6 +335,22 @@ export default function DashboardPage() {
                   </li>
                 ))}
               </ul>
+
+              {/* Pagination */}
+              <div className="flex items-center justify-between px-6 py-4 border-t border-gray-200 dark:border-gray-700">
+                <span className="text-sm text-gray-500 dark:text-gray-400">
+                  Showing {links.length} of {total} links
+                </span>
+                {nextCursor && (
+                  <button
+                    onClick={loadMoreLinks}
+                    disabled={isLoadingMore}
+                    className="inline-flex items-center px-4 py-2 border border-gray-300 dark:border-gray-600 text-sm font-medium rounded-md text-gray-700 dark:text-gray-300 bg-white dark:bg-gray-700 hover:bg-gray-50 dark:hover:bg-gray-600 disabled:opacity-50"
+                  >
+                    {isLoadingMore ? "Loading..." : "Load more"}
+                  </button>
+                )}
+              </div>
             </div>
           )}
         </div>
@@ -1,6 +1,6 @@
 "use client";
 
-import { useState } from "react";
+import { useEffect, useState } from "react";
 import QRCodeDisplay from "./QRCodeDisplay";
 import { api } from "@/lib/api";
 
@@ -23,6 +23,36 @@ export default function URLForm() {
   const [expiresAt, setExpiresAt] = useState("");
   const [error, setError] = useState("");
   const [isLoading, setIsLoading] = useState(false);
+  const [aliasStatus, setAliasStatus] = useState<{
+    available: boolean;
+    reason?: string;
+    suggestions: string[];
+  } | null>(null);
+
+  // Check the alias while the user types, once they pause
+  useEffect(() => {
+    const trimmed = alias.trim();
+    if (!trimmed) {
+      setAliasStatus(null);
+      return;
+    }
+
+    let cancelled = false;
+    const timer = setTimeout(async () => {
+      try {
+        const res = await api.checkAlias(trimmed);
+        if (!res.ok || cancelled) return;
+        setAliasStatus(await res.json());
+      } catch {
+        // The check is only a hint; submitting still validates the alias
+      }
+    }, 400);
+
+    return () => {
+      cancelled = true;
+      clearTimeout(timer);
+    };
+  }, [alias]);
 
   const handleSubmit = async (event: React.FormEvent<HTMLFormElement>) => {
     event.preventDefault();
@@ -41,6 +71,13 @@ export default function URLForm() {
       const data = await res.json();
 
       if (!res.ok) {
+        if (data.suggestions) {
+          setAliasStatus({
+            available: false,
+            reason: data.error,
+            suggestions: data.suggestions,
+          });
+        }
         throw new Error(
           data.error || "Something went wrong. Please try again."
         );
@@ -111,6 +148,31 @@ export default function URLForm() {
               disabled={isLoading}
             />
           </div>
+          {aliasStatus?.available && (
+            <p className="mt-1 text-xs text-green-600 dark:text-green-400">
+              This alias is available
+            </p>
+          )}
+          {aliasStatus && !aliasStatus.available && (
+            <div className="mt-1 text-xs text-red-600 dark:text-red-400">
+              <p>{aliasStatus.reason}</p>
+              {aliasStatus.suggestions.length > 0 && (
+                <div className="flex flex-wrap items-center gap-2 mt-1">
+                  <span className="text-gray-500 dark:text-gray-400">Try:</span>
+                  {aliasStatus.suggestions.map((suggestion) => (
+                    <button
+                      key={suggestion}
+                      type="button"
+                      onClick={() => setAlias(suggestion)}
+                      className="px-2 py-0.5 text-indigo-700 bg-indigo-100 rounded hover:bg-indigo-200 dark:bg-indigo-900 dark:text-indigo-200"
+                    >
+                      {suggestion}
+                    </button>
+                  ))}
+                </div>
+              )}
+            </div>
+          )}
         </div>
 
         <div>
@@ -33,6 +33,10 @@ export async function authenticatedFetch(
   });
 }
 
+// linksPath appends a page cursor to a link listing path
+const linksPath = (path: string, cursor?: string) =>
+  cursor ? `${path}?cursor=${encodeURIComponent(cursor)}` : path;
+
 // API functions
 export const api = {
   // User endpoints
@@ -44,13 +48,14 @@ export const api = {
     return authenticatedFetch("/api/user/stats");
   },
 
-  async getUserLinks() {
-    return authenticatedFetch("/api/user/links");
+  // Pass the previous page's next_cursor to fetch the following page
+  async getUserLinks(cursor?: string) {
+    return authenticatedFetch(linksPath("/api/user/links", cursor));
   },
 
   // Admin endpoints
-  async getAllLinks() {
-    return authenticatedFetch("/api/admin/links");
+  async getAllLinks(cursor?: string) {
+    return authenticatedFetch(linksPath("/api/admin/links", cursor));
   },
 
   async getAnalytics(shortCode: string) {
@@ -69,6 +74,12 @@ export const api = {
     });
   },
 
+  async checkAlias(alias: string) {
+    return authenticatedFetch(
+      `/api/user/aliases/check?alias=${encodeURIComponent(alias)}`
+    );
+  },
+
   async getQRCode(shortCode: string) {
     return fetch(`${API_BASE_URL}/api/qrcode/${shortCode}`);
   },